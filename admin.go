@@ -0,0 +1,149 @@
+package main
+
+import (
+	"github.com/Patrignani/patrignani-rinha-backend-go/internal/workers"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/cache"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/circuitbreaker"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/clock"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/metrics"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/ratelimit"
+	"github.com/gofiber/fiber/v2"
+)
+
+func registerQueueRoutes(admin fiber.Router, queues map[string]workers.QueueWorker, rescreening workers.DelayedQueue) {
+	admin.Get("/queues", func(c *fiber.Ctx) error {
+		stats := fiber.Map{}
+		for name, queue := range queues {
+			stats[name] = queue.Stats()
+		}
+
+		stats["rescreening"] = fiber.Map{"depth": rescreening.Len()}
+
+		return c.Status(fiber.StatusOK).JSON(stats)
+	})
+
+	admin.Put("/queues/:name/workers", func(c *fiber.Ctx) error {
+		queue, ok := queues[c.Params("name")]
+		if !ok {
+			return fiber.NewError(fiber.StatusNotFound, "queue not found")
+		}
+
+		var payload struct {
+			Workers int `json:"workers"`
+		}
+
+		if err := c.BodyParser(&payload); err != nil || payload.Workers < 1 {
+			return fiber.NewError(fiber.StatusBadRequest, "workers must be a positive integer")
+		}
+
+		queue.SetWorkers(payload.Workers)
+
+		return c.Status(fiber.StatusOK).JSON(queue.Stats())
+	})
+}
+
+func registerDeadLetterRoutes(admin fiber.Router, deadLetters workers.DeadLetterStore, screening workers.QueueWorker, clk clock.Clock) {
+	admin.Get("/dead-letters", func(c *fiber.Ctx) error {
+		return c.Status(fiber.StatusOK).JSON(deadLetters.List())
+	})
+
+	admin.Get("/dead-letters/:correlationId", func(c *fiber.Ctx) error {
+		letter, ok := deadLetters.Get(c.Params("correlationId"))
+		if !ok {
+			return fiber.NewError(fiber.StatusNotFound, "dead letter not found")
+		}
+
+		return c.Status(fiber.StatusOK).JSON(letter)
+	})
+
+	admin.Post("/dead-letters/:correlationId/replay", func(c *fiber.Ctx) error {
+		letter, ok := deadLetters.Remove(c.Params("correlationId"))
+		if !ok {
+			return fiber.NewError(fiber.StatusNotFound, "dead letter not found")
+		}
+
+		msg := letter.Message
+		msg.ReprocessedHowManyTimes = 0
+		msg.EnqueueAt = clk.Now().UTC()
+		msg.LastError = ""
+		msg.LastProcessor = ""
+		msg.LastStatusCode = 0
+
+		if err := screening.Send(msg); err != nil {
+			deadLetters.Add(letter.Message, letter.Reason)
+			return fiber.NewError(fiber.StatusServiceUnavailable, "unable to enqueue message: "+err.Error())
+		}
+
+		return c.SendStatus(fiber.StatusAccepted)
+	})
+
+	admin.Delete("/dead-letters/:correlationId", func(c *fiber.Ctx) error {
+		if _, ok := deadLetters.Remove(c.Params("correlationId")); !ok {
+			return fiber.NewError(fiber.StatusNotFound, "dead letter not found")
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+}
+
+func registerRateLimitRoutes(admin fiber.Router, limiters map[string]ratelimit.RateLimiter) {
+	admin.Get("/rate-limits", func(c *fiber.Ctx) error {
+		stats := fiber.Map{}
+		for name, limiter := range limiters {
+			stats[name] = limiter.Stats()
+		}
+
+		return c.Status(fiber.StatusOK).JSON(stats)
+	})
+
+	admin.Put("/rate-limits/:processor", func(c *fiber.Ctx) error {
+		limiter, ok := limiters[c.Params("processor")]
+		if !ok {
+			return fiber.NewError(fiber.StatusNotFound, "processor not found")
+		}
+
+		var payload struct {
+			Rate  float64 `json:"rate"`
+			Burst int     `json:"burst"`
+		}
+
+		if err := c.BodyParser(&payload); err != nil || payload.Rate < 0 || payload.Burst < 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "rate and burst must be non-negative numbers")
+		}
+
+		limiter.SetRate(payload.Rate, payload.Burst)
+
+		return c.Status(fiber.StatusOK).JSON(limiter.Stats())
+	})
+}
+
+// registerLatencyRoutes expõe os percentis de cada etapa: espera em cada fila
+// (queue.*), tempo agendado na sala de espera (delay.rescreening), chamada
+// aos processadores (processor.*) e o total desde EnqueueAt (payment.endToEnd).
+func registerLatencyRoutes(admin fiber.Router, latency metrics.Registry) {
+	admin.Get("/latency", func(c *fiber.Ctx) error {
+		return c.Status(fiber.StatusOK).JSON(latency.Snapshot())
+	})
+}
+
+func registerHealthRoutes(admin fiber.Router, memoryCache cache.AtomicCache, processors []string) {
+	admin.Get("/processors", func(c *fiber.Ctx) error {
+		health := fiber.Map{}
+		for _, processor := range processors {
+			health[processor] = memoryCache.GetProcessorHealth(processor)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(health)
+	})
+}
+
+func registerCircuitBreakerRoutes(admin fiber.Router, breakers map[string]circuitbreaker.CircuitBreaker) {
+	admin.Get("/circuit-breakers", func(c *fiber.Ctx) error {
+		stats := fiber.Map{}
+		for name, breaker := range breakers {
+			stats[name] = breaker.Stats()
+		}
+
+		return c.Status(fiber.StatusOK).JSON(stats)
+	})
+}
@@ -0,0 +1,159 @@
+package repositories
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/models"
+)
+
+const maxFlushBackoff = time.Second
+
+type BatchOptions struct {
+	Size     int
+	Interval time.Duration
+	Retries  int
+}
+
+type batchItem struct {
+	payment models.PaymentDb
+	done    chan error
+}
+
+// BatchPaymentRepositoryImp agrupa as chamadas de Insert e grava em lote quando
+// o lote enche ou quando Interval passa desde o primeiro item. Insert só
+// retorna depois que o lote do pagamento foi gravado; um lote que falha é
+// regravado até dar certo ou até o ctx de NewBatchPaymentRepository acabar,
+// e só nesse caso quem espera recebe o erro.
+type BatchPaymentRepositoryImp struct {
+	repo   PaymentRepository
+	opts   BatchOptions
+	items  chan batchItem
+	closed chan struct{}
+}
+
+func NewBatchPaymentRepository(ctx context.Context, repo PaymentRepository, opts BatchOptions) PaymentRepository {
+	b := &BatchPaymentRepositoryImp{
+		repo:   repo,
+		opts:   opts,
+		items:  make(chan batchItem),
+		closed: make(chan struct{}),
+	}
+
+	go b.run(ctx)
+
+	return b
+}
+
+func (b *BatchPaymentRepositoryImp) Insert(ctx context.Context, payment models.PaymentDb) error {
+	item := batchItem{payment: payment, done: make(chan error, 1)}
+
+	select {
+	case b.items <- item:
+	case <-b.closed:
+		return b.repo.Insert(ctx, payment)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-item.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *BatchPaymentRepositoryImp) InsertBatch(ctx context.Context, payments []models.PaymentDb) error {
+	return b.repo.InsertBatch(ctx, payments)
+}
+
+func (b *BatchPaymentRepositoryImp) GetPaymentSummary(ctx context.Context, from, to *time.Time) (models.SummaryResponse, error) {
+	return b.repo.GetPaymentSummary(ctx, from, to)
+}
+
+func (b *BatchPaymentRepositoryImp) PurgeAll(ctx context.Context) error {
+	return b.repo.PurgeAll(ctx)
+}
+
+func (b *BatchPaymentRepositoryImp) run(ctx context.Context) {
+	defer close(b.closed)
+
+	timer := time.NewTimer(b.opts.Interval)
+	timer.Stop()
+
+	var batch []batchItem
+
+	for {
+		select {
+		case <-ctx.Done():
+			for _, item := range batch {
+				item.done <- ctx.Err()
+			}
+			return
+		case item := <-b.items:
+			if len(batch) == 0 {
+				timer.Reset(b.opts.Interval)
+			}
+
+			batch = append(batch, item)
+			if len(batch) >= b.opts.Size {
+				timer.Stop()
+				go b.flush(ctx, batch)
+				batch = nil
+			}
+		case <-timer.C:
+			if len(batch) > 0 {
+				go b.flush(ctx, batch)
+				batch = nil
+			}
+		}
+	}
+}
+
+func (b *BatchPaymentRepositoryImp) flush(ctx context.Context, batch []batchItem) {
+	payments := make([]models.PaymentDb, len(batch))
+	for i, item := range batch {
+		payments[i] = item.payment
+	}
+
+	err := b.insert(ctx, payments)
+	for _, item := range batch {
+		item.done <- err
+	}
+}
+
+func (b *BatchPaymentRepositoryImp) insert(ctx context.Context, payments []models.PaymentDb) error {
+	for attempt := 1; ; attempt++ {
+		err := b.repo.InsertBatch(ctx, payments)
+		if err == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		log.Printf("Erro ao gravar lote de %d pagamentos (tentativa %d): %v", len(payments), attempt, err)
+
+		timer := time.NewTimer(b.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff cresce 10ms por tentativa até Retries tentativas e depois fica em
+// maxFlushBackoff: o lote já foi confirmado pelo processador e só sai daqui
+// sem gravar quando ctx acaba.
+func (b *BatchPaymentRepositoryImp) backoff(attempt int) time.Duration {
+	if attempt > b.opts.Retries {
+		return maxFlushBackoff
+	}
+
+	return min(time.Duration(attempt)*10*time.Millisecond, maxFlushBackoff)
+}
@@ -2,6 +2,8 @@ package repositories
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/models"
@@ -10,6 +12,7 @@ import (
 
 type PaymentRepository interface {
 	Insert(ctx context.Context, payment models.PaymentDb) error
+	InsertBatch(ctx context.Context, payments []models.PaymentDb) error
 	GetPaymentSummary(ctx context.Context, from, to *time.Time) (models.SummaryResponse, error)
 	PurgeAll(ctx context.Context) error
 }
@@ -24,25 +27,54 @@ func NewPaymentRepository(pg storage.PostgresClient) PaymentRepository {
 	}
 }
 
+// Insert ignora um correlationId já gravado, então regravar um pagamento
+// confirmado é seguro.
 func (p *PaymentRepositoryImp) Insert(ctx context.Context, payment models.PaymentDb) error {
 	sql := `
-		INSERT INTO entry_history (correlationId, amount, fallback, created_at)
+		INSERT INTO entry_history (correlationId, amount, processor, created_at)
 		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (correlationId) DO NOTHING
 	`
 	_, err := p.pg.Exec(ctx, sql,
 		payment.CorrelationId,
 		payment.Amount,
-		payment.Fallback,
+		payment.Processor,
 		payment.CreatedAt,
 	)
 
 	return err
 }
 
+// InsertBatch grava vários pagamentos num único INSERT. ON CONFLICT torna a
+// reexecução de um lote que falhou pela metade segura.
+func (p *PaymentRepositoryImp) InsertBatch(ctx context.Context, payments []models.PaymentDb) error {
+	if len(payments) == 0 {
+		return nil
+	}
+
+	var sql strings.Builder
+	sql.WriteString("INSERT INTO entry_history (correlationId, amount, processor, created_at) VALUES ")
+
+	args := make([]interface{}, 0, len(payments)*4)
+	for i, payment := range payments {
+		if i > 0 {
+			sql.WriteString(", ")
+		}
+		n := i * 4
+		fmt.Fprintf(&sql, "($%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4)
+		args = append(args, payment.CorrelationId, payment.Amount, payment.Processor, payment.CreatedAt)
+	}
+
+	sql.WriteString(" ON CONFLICT (correlationId) DO NOTHING")
+
+	_, err := p.pg.Exec(ctx, sql.String(), args...)
+	return err
+}
+
 func (p *PaymentRepositoryImp) GetPaymentSummary(ctx context.Context, from, to *time.Time) (models.SummaryResponse, error) {
 	query := `
 		SELECT 
-			fallback,
+			processor,
 			COUNT(*) AS total_requests,
 			SUM(amount) AS total_amount
 		FROM 
@@ -51,36 +83,29 @@ func (p *PaymentRepositoryImp) GetPaymentSummary(ctx context.Context, from, to *
 			($1::timestamp IS NULL OR created_at >= $1)
 			AND ($2::timestamp IS NULL OR created_at <= $2)
 		GROUP BY 
-			fallback;
+			processor;
 	`
 
 	rows, err := p.pg.Query(ctx, query, from, to)
 	if err != nil {
-		return models.SummaryResponse{}, err
+		return nil, err
 	}
 	defer rows.Close()
 
-	var summary models.SummaryResponse
+	summary := models.SummaryResponse{}
 
 	for rows.Next() {
-		var fallback bool
+		var processor string
 		var totalRequests int
 		var totalAmount float64
 
-		if err := rows.Scan(&fallback, &totalRequests, &totalAmount); err != nil {
-			return models.SummaryResponse{}, err
+		if err := rows.Scan(&processor, &totalRequests, &totalAmount); err != nil {
+			return nil, err
 		}
 
-		if fallback {
-			summary.Fallback = models.PaymentSummary{
-				TotalRequests: totalRequests,
-				TotalAmount:   totalAmount,
-			}
-		} else {
-			summary.Default = models.PaymentSummary{
-				TotalRequests: totalRequests,
-				TotalAmount:   totalAmount,
-			}
+		summary[processor] = models.PaymentSummary{
+			TotalRequests: totalRequests,
+			TotalAmount:   totalAmount,
 		}
 	}
 
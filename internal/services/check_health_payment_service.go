@@ -6,9 +6,11 @@ import (
 	"log"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/cache"
 	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/clients"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/clock"
 	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/config"
 	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/models"
 )
@@ -20,56 +22,59 @@ type CheckHealthPaymentService interface {
 type CheckHealthPaymentServiceImp struct {
 	httpRequest *http.Client
 	memoryCache cache.AtomicCache
+	clock       clock.Clock
 }
 
-func NewCheckHealthPaymentService(httpRequest *http.Client, memoryCache cache.AtomicCache) CheckHealthPaymentService {
+func NewCheckHealthPaymentService(httpRequest *http.Client, memoryCache cache.AtomicCache, clk clock.Clock) CheckHealthPaymentService {
 	return &CheckHealthPaymentServiceImp{
 		httpRequest: httpRequest,
 		memoryCache: memoryCache,
+		clock:       clk,
 	}
 }
 
 func (c *CheckHealthPaymentServiceImp) SetStatusPayment(ctx context.Context) error {
 	var wg sync.WaitGroup
-	var healthDefault *models.Health
-	var healthfallback *models.Health
-	var errDefault error
-	var errFallback error
-	var fallbackFail, defaultFail bool
-
-	wg.Add(2)
-	go func() {
-		defer wg.Done()
-		url := fmt.Sprintf("%s/payments/service-health", config.Env.DefaultUrl)
-		healthDefault, errDefault = c.getStatus(ctx, url)
-		if errDefault != nil {
-			log.Printf("Error get health DEFAULT: %v", errFallback)
-			return
-		}
-
-		defaultFail = healthDefault != nil && healthDefault.Failing || healthDefault.MinResponseTime > config.Env.LimitTimeHealth
-	}()
-
-	go func() {
-		defer wg.Done()
-		url := fmt.Sprintf("%s/payments/service-health", config.Env.FallbackUrl)
-		healthfallback, errFallback = c.getStatus(ctx, url)
-		if errFallback != nil {
-			log.Printf("Error get health fallback: %v", errFallback)
-			return
-		}
-
-		fallbackFail = healthfallback != nil && healthfallback.Failing || healthfallback.MinResponseTime > config.Env.LimitTimeHealth
-	}()
 
-	wg.Wait()
+	for _, processor := range config.Env.Processors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.checkProcessor(ctx, processor.Name, processor.Url)
+		}()
+	}
 
-	c.memoryCache.SetHealthDeafultApi(defaultFail)
-	c.memoryCache.SetHealthFallbackApi(fallbackFail)
+	wg.Wait()
 
 	return nil
 }
 
+// checkProcessor grava HealthUnknown quando a consulta falha, em vez de
+// deixar o processador como saudável.
+func (c *CheckHealthPaymentServiceImp) checkProcessor(ctx context.Context, processor, baseUrl string) {
+	url := fmt.Sprintf("%s/payments/service-health", baseUrl)
+
+	health, err := c.getStatus(ctx, url)
+	if err != nil {
+		log.Printf("Error get health %s: %v", processor, err)
+		c.memoryCache.SetHealthCheck(processor, cache.HealthUnknown, 0, time.Time{})
+		return
+	}
+
+	c.memoryCache.SetHealthCheck(processor, healthState(health), health.MinResponseTime, c.clock.Now().UTC())
+}
+
+func healthState(health *models.Health) cache.HealthState {
+	switch {
+	case health.Failing:
+		return cache.HealthFailing
+	case health.MinResponseTime > config.Env.LimitTimeHealth:
+		return cache.HealthDegraded
+	default:
+		return cache.HealthHealthy
+	}
+}
+
 func (c *CheckHealthPaymentServiceImp) getStatus(ctx context.Context, url string) (*models.Health, error) {
 	var health models.Health
 
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Patrignani/patrignani-rinha-backend-go/internal/workers"
+)
+
+type DeadLetterPolicy struct {
+	MaxAttempts int
+	MaxAge      time.Duration
+}
+
+// Exhausted informa se a mensagem já passou do limite de tentativas ou de
+// idade e, nesse caso, o motivo. Limites zerados ficam desativados.
+func (p DeadLetterPolicy) Exhausted(msg workers.Message, now time.Time) (string, bool) {
+	if p.MaxAttempts > 0 && msg.ReprocessedHowManyTimes >= p.MaxAttempts {
+		return fmt.Sprintf("max attempts reached (%d)", msg.ReprocessedHowManyTimes), true
+	}
+
+	if p.MaxAge > 0 && !msg.EnqueueAt.IsZero() && now.Sub(msg.EnqueueAt) > p.MaxAge {
+		return fmt.Sprintf("max age exceeded (%s)", now.Sub(msg.EnqueueAt).Round(time.Millisecond)), true
+	}
+
+	return "", false
+}
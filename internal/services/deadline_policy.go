@@ -0,0 +1,28 @@
+package services
+
+import (
+	"time"
+
+	"github.com/Patrignani/patrignani-rinha-backend-go/internal/workers"
+)
+
+type DeadlineAction string
+
+const (
+	// DeadlineUrgent desvia a mensagem para a fila urgente, com workers próprios.
+	DeadlineUrgent DeadlineAction = "urgent"
+	// DeadlineAnyProcessor envia direto para um processador, mesmo que ambos
+	// estejam marcados como fora, ignorando custo e sala de espera.
+	DeadlineAnyProcessor DeadlineAction = "any"
+	// DeadlineExpire manda a mensagem para a dead letter com motivo "expired".
+	DeadlineExpire DeadlineAction = "expire"
+)
+
+type DeadlinePolicy struct {
+	MaxAge time.Duration
+	Action DeadlineAction
+}
+
+func (p DeadlinePolicy) Expired(msg workers.Message, now time.Time) bool {
+	return p.MaxAge > 0 && !msg.EnqueueAt.IsZero() && now.Sub(msg.EnqueueAt) > p.MaxAge
+}
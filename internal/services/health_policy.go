@@ -0,0 +1,71 @@
+package services
+
+import (
+	"time"
+
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/cache"
+)
+
+type UnknownHealthAction string
+
+const (
+	// UnknownAsFailing trata o processador sem leitura confiável como fora.
+	UnknownAsFailing UnknownHealthAction = "failing"
+	// UnknownAsHealthy trata o processador sem leitura confiável como de pé.
+	UnknownAsHealthy UnknownHealthAction = "healthy"
+	// UnknownAsLast usa a última leitura conclusiva, por mais velha que seja.
+	UnknownAsLast UnknownHealthAction = "last"
+)
+
+// HealthPolicy decide como o roteamento lê o ProcessorHealth do cache.
+// Leituras mais velhas que StaleAfter viram HealthUnknown (zero desativa) e
+// Unknown define o que fazer nesse estado. Degraded conta como fora, como o
+// limite de LIMIT_TIME_HEALTH sempre contou. ObservationTTL é a idade máxima
+// das medidas de tráfego (zero desativa).
+type HealthPolicy struct {
+	StaleAfter     time.Duration
+	Unknown        UnknownHealthAction
+	ObservationTTL time.Duration
+}
+
+func (p HealthPolicy) State(health cache.ProcessorHealth, now time.Time) cache.HealthState {
+	if health.State == cache.HealthUnknown || health.State == "" {
+		return cache.HealthUnknown
+	}
+
+	if p.StaleAfter > 0 && now.Sub(health.CheckedAt) > p.StaleAfter {
+		return cache.HealthUnknown
+	}
+
+	return health.State
+}
+
+func (p HealthPolicy) Failing(health cache.ProcessorHealth, now time.Time) bool {
+	switch p.State(health, now) {
+	case cache.HealthHealthy:
+		return false
+	case cache.HealthUnknown:
+		switch p.Unknown {
+		case UnknownAsHealthy:
+			return false
+		case UnknownAsLast:
+			return health.Failing
+		default:
+			return true
+		}
+	default:
+		return true
+	}
+}
+
+// Traffic devolve health sem EwmaMs, percentis e ErrorRate quando a última
+// observação passou de ObservationTTL. Um processador que deixou de receber
+// tráfego volta a ser comparado pelo health check, e não pela latência que
+// tinha quando foi abandonado.
+func (p HealthPolicy) Traffic(health cache.ProcessorHealth, now time.Time) cache.ProcessorHealth {
+	if p.ObservationTTL > 0 && now.Sub(health.ObservedAt) > p.ObservationTTL {
+		health.EwmaMs, health.P50Ms, health.P95Ms, health.P99Ms, health.ErrorRate = 0, 0, 0, 0, 0
+	}
+
+	return health
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -11,111 +12,199 @@ import (
 	"github.com/Patrignani/patrignani-rinha-backend-go/internal/repositories"
 	"github.com/Patrignani/patrignani-rinha-backend-go/internal/workers"
 	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/cache"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/circuitbreaker"
 	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/clients"
-	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/config"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/clock"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/metrics"
 	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/models"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/ratelimit"
 )
 
+const maxInsertBackoff = time.Second
+
 type PaymentService interface {
-	ExecuteDefault(ctx context.Context, msg workers.Message) error
-	ExecuteFallback(ctx context.Context, msg workers.Message) error
+	Execute(ctx context.Context, processor string, msg workers.Message) error
+	ExecuteUrgent(ctx context.Context, msg workers.Message) error
+}
+
+// ProcessorOptions reúne o que é específico de cada processador nas chamadas
+// feitas por PaymentServiceImp. Campos nil ficam desativados.
+type ProcessorOptions struct {
+	Name     string
+	Url      string
+	Observer workers.LatencyObserver
+	Limiter  ratelimit.RateLimiter
+	Latency  *metrics.Histogram
+	Breaker  circuitbreaker.CircuitBreaker
 }
 
+// endToEnd mede o tempo entre EnqueueAt e a confirmação do processador.
+// processors vem em ordem de prioridade.
 type PaymentServiceImp struct {
 	httpRequest *http.Client
 	waitingRoom workers.QueueWorker
 	memoryCache cache.AtomicCache
 	repo        repositories.PaymentRepository
+	processors  []ProcessorOptions
+	endToEnd    *metrics.Histogram
+	clock       clock.Clock
+	health      HealthPolicy
 }
 
-func NewPaymentService(httpRequest *http.Client, waitingRoom workers.QueueWorker, memoryCache cache.AtomicCache, repo repositories.PaymentRepository) PaymentService {
+func NewPaymentService(httpRequest *http.Client, waitingRoom workers.QueueWorker, memoryCache cache.AtomicCache, repo repositories.PaymentRepository, processors []ProcessorOptions, endToEnd *metrics.Histogram, clk clock.Clock, health HealthPolicy) PaymentService {
 	return &PaymentServiceImp{
 		httpRequest: httpRequest,
 		waitingRoom: waitingRoom,
 		memoryCache: memoryCache,
 		repo:        repo,
+		processors:  processors,
+		endToEnd:    endToEnd,
+		clock:       clk,
+		health:      health,
 	}
 }
 
-func (p *PaymentServiceImp) ExecuteDefault(ctx context.Context, msg workers.Message) error {
-	url := fmt.Sprintf("%s/payments", config.Env.DefaultUrl)
+func (p *PaymentServiceImp) Execute(ctx context.Context, processor string, msg workers.Message) error {
+	if msg.ConfirmedBy != "" {
+		return p.record(ctx, msg)
+	}
+
+	options, ok := p.options(processor)
+	if !ok {
+		return fmt.Errorf("processador desconhecido: %s", processor)
+	}
+
+	url := fmt.Sprintf("%s/payments", options.Url)
 
-	statusCode, err := p.postPayment(ctx, msg, url)
+	statusCode, err := p.postPayment(ctx, msg, url, options)
 
 	if err != nil && statusCode != 422 {
-		log.Printf("ExecuteDefault - error %v \n", err)
-		if !config.Env.EnableCheckHealthCheck {
-			p.memoryCache.SetHealthDeafultApi(true)
-		}
+		log.Printf("Execute %s - error %v \n", processor, err)
 
-		p.waitingRoom.Send(msg)
+		msg.LastError = err.Error()
+		msg.LastProcessor = processor
+		msg.LastStatusCode = statusCode
+		msg.BreakerRejected = errors.Is(err, circuitbreaker.ErrOpen)
 
-		return err
-	}
+		if errSend := p.waitingRoom.Send(msg); errSend != nil {
+			log.Printf("Execute %s - waiting room %v \n", processor, errSend)
+		}
 
-	if !config.Env.EnableCheckHealthCheck {
-		p.memoryCache.SetHealthDeafultApi(false)
+		return err
 	}
 
 	if statusCode != 422 {
-		if err := p.repo.Insert(ctx, models.PaymentDb{
-			CorrelationId: msg.CorrelationId,
-			Amount:        msg.Amount,
-			Fallback:      false,
-			CreatedAt:     time.Now().UTC(),
-		}); err != nil {
-			log.Printf("ExecuteDefault - insert %v \n", err)
+		msg.ConfirmedBy = processor
+		msg.ConfirmedAt = p.clock.Now().UTC()
+		if err := p.record(ctx, msg); err != nil {
 			return err
 		}
 	}
 
-	log.Printf("ExecuteDefault - inseriu \n")
+	log.Printf("Execute %s - inseriu \n", processor)
 	return nil
 }
 
-func (p *PaymentServiceImp) ExecuteFallback(ctx context.Context, msg workers.Message) error {
-	url := fmt.Sprintf("%s/payments", config.Env.FallbackUrl)
-
-	statusCode, err := p.postPayment(ctx, msg, url)
+// ExecuteUrgent atende mensagens que passaram do prazo: usa o primeiro
+// processador de pé, sem considerar custo, e tenta o de maior prioridade se
+// todos estiverem fora.
+func (p *PaymentServiceImp) ExecuteUrgent(ctx context.Context, msg workers.Message) error {
+	now := p.clock.Now().UTC()
 
-	if err != nil && statusCode != 422 {
-		log.Printf("ExecuteFallback - error %v \n", err)
-		if !config.Env.EnableCheckHealthCheck {
-			p.memoryCache.SetHealthFallbackApi(true)
+	for _, processor := range p.processors {
+		if !p.failing(processor, now) {
+			return p.Execute(ctx, processor.Name, msg)
 		}
+	}
 
-		p.waitingRoom.Send(msg)
+	return p.Execute(ctx, p.processors[0].Name, msg)
+}
+
+// record grava um pagamento já confirmado. Se insert desistir porque ctx
+// acabou, a mensagem vai para a sala de espera com ConfirmedBy, e a próxima
+// passagem por Execute só faz a gravação, sem chamar o processador de novo.
+func (p *PaymentServiceImp) record(ctx context.Context, msg workers.Message) error {
+	if err := p.insert(ctx, models.PaymentDb{
+		CorrelationId: msg.CorrelationId,
+		Amount:        msg.Amount,
+		Processor:     msg.ConfirmedBy,
+		CreatedAt:     msg.ConfirmedAt,
+	}); err != nil {
+		log.Printf("Execute %s - insert %v \n", msg.ConfirmedBy, err)
+
+		if errSend := p.waitingRoom.Send(msg); errSend != nil {
+			log.Printf("Execute %s - waiting room %v \n", msg.ConfirmedBy, errSend)
+		}
 
 		return err
 	}
 
-	if !config.Env.EnableCheckHealthCheck {
-		p.memoryCache.SetHealthFallbackApi(false)
+	p.endToEnd.Record(p.clock.Since(msg.EnqueueAt))
+
+	return nil
+}
+
+// insert repete a gravação até ela dar certo: o processador já confirmou o
+// pagamento e ele não pode ficar fora do resumo. Só desiste quando ctx acaba,
+// o que acontece com o prazo de encerramento esgotado.
+func (p *PaymentServiceImp) insert(ctx context.Context, payment models.PaymentDb) error {
+	for attempt := 1; ; attempt++ {
+		err := p.repo.Insert(ctx, payment)
+		if err == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		log.Printf("Execute %s - insert %s (tentativa %d) %v \n", payment.Processor, payment.CorrelationId, attempt, err)
+
+		timer := p.clock.NewTimer(min(time.Duration(attempt)*10*time.Millisecond, maxInsertBackoff))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C():
+		}
 	}
+}
 
-	if statusCode != 422 {
-		if err := p.repo.Insert(ctx, models.PaymentDb{
-			CorrelationId: msg.CorrelationId,
-			Amount:        msg.Amount,
-			Fallback:      true,
-			CreatedAt:     time.Now().UTC(),
-		}); err != nil {
-			log.Printf("ExecuteFallback - insert %v \n", err)
-			return err
+func (p *PaymentServiceImp) options(processor string) (ProcessorOptions, bool) {
+	for _, options := range p.processors {
+		if options.Name == processor {
+			return options, true
 		}
 	}
 
-	log.Printf("ExecuteFallback - inseriu \n")
+	return ProcessorOptions{}, false
+}
 
-	return nil
+func (p *PaymentServiceImp) failing(processor ProcessorOptions, now time.Time) bool {
+	if processor.Breaker != nil && !processor.Breaker.Available() {
+		return true
+	}
+
+	return p.health.Failing(p.memoryCache.GetProcessorHealth(processor.Name), now)
 }
 
-func (p *PaymentServiceImp) postPayment(ctx context.Context, msg workers.Message, url string) (int, error) {
+// postPayment devolve circuitbreaker.ErrOpen sem chamar o processador quando
+// o disjuntor recusa a chamada.
+func (p *PaymentServiceImp) postPayment(ctx context.Context, msg workers.Message, url string, processor ProcessorOptions) (int, error) {
+	if processor.Limiter != nil {
+		if _, err := processor.Limiter.Wait(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	if processor.Breaker != nil && !processor.Breaker.Allow() {
+		return 0, circuitbreaker.ErrOpen
+	}
 
 	reqBody := models.PaymentRequest{
 		CorrelationId: msg.CorrelationId,
 		Amount:        msg.Amount,
-		RequestedAt:   time.Now().UTC(),
+		RequestedAt:   p.clock.Now().UTC(),
 	}
 
 	bodyBytes, err := json.Marshal(reqBody)
@@ -123,6 +212,7 @@ func (p *PaymentServiceImp) postPayment(ctx context.Context, msg workers.Message
 		log.Fatal("Erro ao serializar o corpo:", err)
 	}
 
+	start := p.clock.Now()
 	resp, err := clients.Do[any](p.httpRequest, clients.RequestParams{
 		Method: "POST",
 		URL:    url,
@@ -133,5 +223,29 @@ func (p *PaymentServiceImp) postPayment(ctx context.Context, msg workers.Message
 		Ctx:  ctx,
 	}, nil)
 
-	return resp.StatusCode, err
+	elapsed := p.clock.Since(start)
+	processor.Latency.Record(elapsed)
+
+	statusCode := 0
+	if resp != nil {
+		statusCode = resp.StatusCode
+	}
+
+	// 422 indica pagamento já processado: o processador respondeu bem.
+	callErr := err
+	if statusCode == 422 {
+		callErr = nil
+	}
+
+	p.memoryCache.Observe(processor.Name, elapsed, callErr, p.clock.Now().UTC())
+
+	if processor.Breaker != nil {
+		processor.Breaker.Record(callErr)
+	}
+
+	if processor.Observer != nil {
+		processor.Observer.Observe(elapsed, callErr)
+	}
+
+	return statusCode, err
 }
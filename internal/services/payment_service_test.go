@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Patrignani/patrignani-rinha-backend-go/internal/workers"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/cache"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/clock"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/models"
+)
+
+// failingRepository recusa toda gravação, como um banco fora do ar.
+type failingRepository struct{}
+
+func (failingRepository) Insert(context.Context, models.PaymentDb) error {
+	return errors.New("banco fora do ar")
+}
+
+func (failingRepository) InsertBatch(context.Context, []models.PaymentDb) error {
+	return errors.New("banco fora do ar")
+}
+
+func (failingRepository) GetPaymentSummary(context.Context, *time.Time, *time.Time) (models.SummaryResponse, error) {
+	return nil, errors.New("banco fora do ar")
+}
+
+func (failingRepository) PurgeAll(context.Context) error {
+	return errors.New("banco fora do ar")
+}
+
+func TestExecuteKeepsConfirmedPaymentWhenInsertGivesUp(t *testing.T) {
+	waitingRoom := workers.NewQueueWorker(workers.QueueOptions{Buffer: 10})
+	payments := NewPaymentService(nil, waitingRoom, cache.NewCostRoutingThresholdCache(0), failingRepository{},
+		[]ProcessorOptions{{Name: "default"}}, nil, clock.Real, HealthPolicy{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	confirmedAt := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	msg := workers.Message{CorrelationId: "a", ConfirmedBy: "default", ConfirmedAt: confirmedAt}
+
+	done := make(chan error, 1)
+	go func() { done <- payments.Execute(ctx, "fallback", msg) }()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Execute() = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Execute não desistiu da gravação depois do fim de ctx")
+	}
+
+	msgs := waitingRoom.Snapshot()
+	if len(msgs) != 1 {
+		t.Fatalf("sala de espera com %d mensagens, want 1", len(msgs))
+	}
+
+	if msgs[0].ConfirmedBy != "default" || !msgs[0].ConfirmedAt.Equal(confirmedAt) {
+		t.Errorf("mensagem guardada sem a confirmação: %+v", msgs[0])
+	}
+}
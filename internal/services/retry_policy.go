@@ -0,0 +1,86 @@
+package services
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/Patrignani/patrignani-rinha-backend-go/internal/workers"
+)
+
+type JitterMode string
+
+const (
+	JitterNone         JitterMode = "none"
+	JitterFull         JitterMode = "full"
+	JitterDecorrelated JitterMode = "decorrelated"
+)
+
+// RetryPolicy decide quanto tempo uma mensagem espera na sala de espera e,
+// enquanto todos os processadores estão marcados como fora, qual a chance de
+// ela ser enviada mesmo assim. Ambos crescem com ReprocessedHowManyTimes.
+type RetryPolicy struct {
+	BaseDelay  time.Duration
+	Multiplier float64
+	MaxDelay   time.Duration
+	Jitter     JitterMode
+
+	BaseChance          int
+	ChanceIncrement     int
+	MaxChance           int
+	FallbackProbeChance int
+
+	// Rand devolve um valor em [0, 1); nil usa math/rand.
+	Rand func() float64
+}
+
+func (p RetryPolicy) Delay(msg workers.Message) time.Duration {
+	backoff := float64(p.BaseDelay) * math.Pow(p.Multiplier, float64(msg.ReprocessedHowManyTimes))
+	if p.MaxDelay > 0 && backoff > float64(p.MaxDelay) {
+		backoff = float64(p.MaxDelay)
+	}
+
+	switch p.Jitter {
+	case JitterFull:
+		return time.Duration(p.random() * backoff)
+	case JitterDecorrelated:
+		previous := msg.RetryDelay
+		if previous < p.BaseDelay {
+			previous = p.BaseDelay
+		}
+
+		upper := float64(previous) * 3
+		delay := float64(p.BaseDelay) + p.random()*(upper-float64(p.BaseDelay))
+		if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+			delay = float64(p.MaxDelay)
+		}
+
+		return time.Duration(delay)
+	default:
+		return time.Duration(backoff)
+	}
+}
+
+func (p RetryPolicy) RetryChance(msg workers.Message) int {
+	if msg.ReprocessedHowManyTimes <= 0 {
+		return 0
+	}
+
+	return min(p.BaseChance+p.ChanceIncrement*msg.ReprocessedHowManyTimes, p.MaxChance)
+}
+
+func (p RetryPolicy) ShouldRetry(msg workers.Message) bool {
+	return p.random()*100 < float64(p.RetryChance(msg))
+}
+
+func (p RetryPolicy) ProbeFallback() bool {
+	return p.random()*100 < float64(p.FallbackProbeChance)
+}
+
+func (p RetryPolicy) random() float64 {
+	if p.Rand != nil {
+		return p.Rand()
+	}
+
+	return rand.Float64()
+}
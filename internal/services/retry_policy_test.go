@@ -0,0 +1,137 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Patrignani/patrignani-rinha-backend-go/internal/workers"
+)
+
+func fixedRand(v float64) func() float64 {
+	return func() float64 { return v }
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		msg    workers.Message
+		want   time.Duration
+	}{
+		{
+			name:   "sem jitter na primeira tentativa usa BaseDelay",
+			policy: RetryPolicy{BaseDelay: 100 * time.Millisecond, Multiplier: 2, Jitter: JitterNone},
+			msg:    workers.Message{},
+			want:   100 * time.Millisecond,
+		},
+		{
+			name:   "sem jitter cresce com Multiplier",
+			policy: RetryPolicy{BaseDelay: 100 * time.Millisecond, Multiplier: 2, Jitter: JitterNone},
+			msg:    workers.Message{ReprocessedHowManyTimes: 3},
+			want:   800 * time.Millisecond,
+		},
+		{
+			name:   "sem jitter respeita MaxDelay",
+			policy: RetryPolicy{BaseDelay: 100 * time.Millisecond, Multiplier: 2, MaxDelay: 500 * time.Millisecond, Jitter: JitterNone},
+			msg:    workers.Message{ReprocessedHowManyTimes: 10},
+			want:   500 * time.Millisecond,
+		},
+		{
+			name:   "Multiplier 1 mantém o atraso fixo",
+			policy: RetryPolicy{BaseDelay: 200 * time.Millisecond, Multiplier: 1, Jitter: JitterNone},
+			msg:    workers.Message{ReprocessedHowManyTimes: 5},
+			want:   200 * time.Millisecond,
+		},
+		{
+			name:   "full sorteia entre zero e o backoff",
+			policy: RetryPolicy{BaseDelay: 100 * time.Millisecond, Multiplier: 2, Jitter: JitterFull, Rand: fixedRand(0.5)},
+			msg:    workers.Message{ReprocessedHowManyTimes: 2},
+			want:   200 * time.Millisecond,
+		},
+		{
+			name:   "full aplica o teto antes do sorteio",
+			policy: RetryPolicy{BaseDelay: 100 * time.Millisecond, Multiplier: 2, MaxDelay: 400 * time.Millisecond, Jitter: JitterFull, Rand: fixedRand(0.5)},
+			msg:    workers.Message{ReprocessedHowManyTimes: 10},
+			want:   200 * time.Millisecond,
+		},
+		{
+			name:   "decorrelated parte de BaseDelay sem atraso anterior",
+			policy: RetryPolicy{BaseDelay: 100 * time.Millisecond, Multiplier: 1, Jitter: JitterDecorrelated, Rand: fixedRand(0.5)},
+			msg:    workers.Message{ReprocessedHowManyTimes: 1},
+			want:   200 * time.Millisecond,
+		},
+		{
+			name:   "decorrelated usa o atraso anterior",
+			policy: RetryPolicy{BaseDelay: 100 * time.Millisecond, Multiplier: 1, Jitter: JitterDecorrelated, Rand: fixedRand(0.5)},
+			msg:    workers.Message{ReprocessedHowManyTimes: 2, RetryDelay: time.Second},
+			want:   1550 * time.Millisecond,
+		},
+		{
+			name:   "decorrelated respeita MaxDelay",
+			policy: RetryPolicy{BaseDelay: 100 * time.Millisecond, Multiplier: 1, MaxDelay: time.Second, Jitter: JitterDecorrelated, Rand: fixedRand(0.9)},
+			msg:    workers.Message{ReprocessedHowManyTimes: 2, RetryDelay: time.Second},
+			want:   time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Delay(tt.msg); got != tt.want {
+				t.Errorf("Delay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyRetryChance(t *testing.T) {
+	policy := RetryPolicy{BaseChance: 30, ChanceIncrement: 10, MaxChance: 80}
+
+	tests := []struct {
+		reprocessed int
+		want        int
+	}{
+		{reprocessed: 0, want: 0},
+		{reprocessed: 1, want: 40},
+		{reprocessed: 3, want: 60},
+		{reprocessed: 5, want: 80},
+		{reprocessed: 20, want: 80},
+	}
+
+	for _, tt := range tests {
+		msg := workers.Message{ReprocessedHowManyTimes: tt.reprocessed}
+		if got := policy.RetryChance(msg); got != tt.want {
+			t.Errorf("RetryChance(%d) = %d, want %d", tt.reprocessed, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	msg := workers.Message{ReprocessedHowManyTimes: 1}
+
+	below := RetryPolicy{BaseChance: 30, ChanceIncrement: 10, MaxChance: 80, Rand: fixedRand(0.39)}
+	if !below.ShouldRetry(msg) {
+		t.Error("ShouldRetry() = false com sorteio abaixo da chance")
+	}
+
+	above := RetryPolicy{BaseChance: 30, ChanceIncrement: 10, MaxChance: 80, Rand: fixedRand(0.4)}
+	if above.ShouldRetry(msg) {
+		t.Error("ShouldRetry() = true com sorteio igual à chance")
+	}
+
+	first := RetryPolicy{BaseChance: 30, ChanceIncrement: 10, MaxChance: 80, Rand: fixedRand(0)}
+	if first.ShouldRetry(workers.Message{}) {
+		t.Error("ShouldRetry() = true para mensagem ainda não reprocessada")
+	}
+}
+
+func TestRetryPolicyProbeFallback(t *testing.T) {
+	policy := RetryPolicy{FallbackProbeChance: 50, Rand: fixedRand(0.49)}
+	if !policy.ProbeFallback() {
+		t.Error("ProbeFallback() = false com sorteio abaixo da chance")
+	}
+
+	policy.Rand = fixedRand(0.5)
+	if policy.ProbeFallback() {
+		t.Error("ProbeFallback() = true com sorteio igual à chance")
+	}
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/Patrignani/patrignani-rinha-backend-go/internal/workers"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/cache"
+)
+
+// RouteNone indica que nenhum processador serve agora; Redirect decide entre
+// nova tentativa e sala de espera.
+const RouteNone = ""
+
+// ProcessorStatus é a visão de um processador no momento do roteamento.
+// Failing já combina HealthPolicy e disjuntor; State é o estado efetivo,
+// com a idade da leitura considerada. Fee e Weight vêm da configuração.
+type ProcessorStatus struct {
+	Name        string
+	Fee         float64
+	Weight      int
+	Failing     bool
+	BreakerOpen bool
+	State       cache.HealthState
+	Health      cache.ProcessorHealth
+}
+
+// usable informa se o processador responde, mesmo que lento: degraded só
+// conta como fora para quem usa Failing.
+func (s ProcessorStatus) usable() bool {
+	return !s.Failing || (s.State == cache.HealthDegraded && !s.BreakerOpen)
+}
+
+// latencyMs prefere o p95 observado no tráfego e cai para o MinResponseTime do
+// health check quando ainda não há observações.
+func (s ProcessorStatus) latencyMs() float64 {
+	if s.Health.P95Ms > 0 {
+		return s.Health.P95Ms
+	}
+
+	return float64(s.Health.MinResponseTime)
+}
+
+// RoutingPolicy recebe os processadores em ordem de prioridade e devolve o
+// nome do escolhido, ou RouteNone.
+type RoutingPolicy interface {
+	Route(msg workers.Message, processors []ProcessorStatus) string
+}
+
+type RoutingOptions struct {
+	// LatencyMargin é quanto outro processador precisa ser mais rápido para a
+	// latency tirar tráfego do preferido.
+	LatencyMargin time.Duration
+	// LatencyCost converte latência em custo para a fee: quanto de taxa vale
+	// cada milissegundo esperado.
+	LatencyCost float64
+	// LatencyBudget é quantas vezes a EWMA do preferido pode ser maior que a
+	// do mais rápido antes da latency-budget desviar para ele.
+	LatencyBudget float64
+	// Rand devolve um valor em [0, 1); nil usa math/rand.
+	Rand func() float64
+}
+
+// NewRoutingPolicy devolve a estratégia pelo nome: strict, weighted, cost,
+// latency, fee ou latency-budget.
+func NewRoutingPolicy(name string, opts RoutingOptions) (RoutingPolicy, error) {
+	switch name {
+	case "strict", "":
+		return StrictRouting{}, nil
+	case "weighted":
+		return WeightedRouting{Rand: opts.Rand}, nil
+	case "cost":
+		return CostAwareRouting{}, nil
+	case "latency":
+		return LatencyAwareRouting{Margin: opts.LatencyMargin}, nil
+	case "fee":
+		return FeeAwareRouting{LatencyCost: opts.LatencyCost}, nil
+	case "latency-budget":
+		return LatencyBudgetRouting{Budget: opts.LatencyBudget}, nil
+	default:
+		return nil, fmt.Errorf("política de roteamento desconhecida: %s", name)
+	}
+}
+
+// preferred devolve o primeiro processador de pé, o de maior prioridade.
+func preferred(processors []ProcessorStatus) (ProcessorStatus, bool) {
+	for _, processor := range processors {
+		if !processor.Failing {
+			return processor, true
+		}
+	}
+
+	return ProcessorStatus{}, false
+}
+
+// StrictRouting usa sempre o processador de maior prioridade que está de pé.
+type StrictRouting struct{}
+
+func (StrictRouting) Route(_ workers.Message, processors []ProcessorStatus) string {
+	if processor, ok := preferred(processors); ok {
+		return processor.Name
+	}
+
+	return RouteNone
+}
+
+// WeightedRouting divide o tráfego entre os processadores de pé na proporção
+// de Weight; sem peso entre eles, cai para a strict.
+type WeightedRouting struct {
+	Rand func() float64
+}
+
+func (w WeightedRouting) Route(msg workers.Message, processors []ProcessorStatus) string {
+	total := 0
+	for _, processor := range processors {
+		if !processor.Failing && processor.Weight > 0 {
+			total += processor.Weight
+		}
+	}
+
+	if total == 0 {
+		return StrictRouting{}.Route(msg, processors)
+	}
+
+	random := rand.Float64
+	if w.Rand != nil {
+		random = w.Rand
+	}
+
+	pick := random() * float64(total)
+	for _, processor := range processors {
+		if processor.Failing || processor.Weight <= 0 {
+			continue
+		}
+
+		pick -= float64(processor.Weight)
+		if pick < 0 {
+			return processor.Name
+		}
+	}
+
+	return StrictRouting{}.Route(msg, processors)
+}
+
+// CostAwareRouting fica no processador de menor taxa enquanto ele responder,
+// mesmo degradado; empate fica com o de maior prioridade.
+type CostAwareRouting struct{}
+
+func (CostAwareRouting) Route(_ workers.Message, processors []ProcessorStatus) string {
+	route := RouteNone
+	cheapest := 0.0
+
+	for _, processor := range processors {
+		if !processor.usable() {
+			continue
+		}
+
+		if route == RouteNone || processor.Fee < cheapest {
+			route, cheapest = processor.Name, processor.Fee
+		}
+	}
+
+	return route
+}
+
+// LatencyAwareRouting fica no preferido, a não ser que outro de pé seja mais
+// rápido que ele por mais de Margin; nesse caso vai para o que mais economiza.
+// Só compara medidas da mesma origem (ver comparableLatencyMs), e quem não tem
+// medida nenhuma nunca tira tráfego do preferido. O p95 de quem ficou sem
+// tráfego some com HealthPolicy.Traffic, e a comparação volta ao health check:
+// é assim que o preferido recupera o tráfego depois de um desvio.
+type LatencyAwareRouting struct {
+	Margin time.Duration
+}
+
+func (l LatencyAwareRouting) Route(_ workers.Message, processors []ProcessorStatus) string {
+	first, ok := preferred(processors)
+	if !ok {
+		return RouteNone
+	}
+
+	margin := float64(l.Margin.Microseconds()) / 1000
+	route, bestSaving := first.Name, margin
+
+	for _, processor := range processors {
+		if processor.Failing || processor.Name == first.Name {
+			continue
+		}
+
+		firstMs, candidateMs, ok := comparableLatencyMs(first, processor)
+		if !ok {
+			continue
+		}
+
+		if saving := firstMs - candidateMs; saving > bestSaving {
+			route, bestSaving = processor.Name, saving
+		}
+	}
+
+	return route
+}
+
+// comparableLatencyMs devolve a latência dos dois processadores pela mesma
+// régua: o p95 observado quando ambos têm tráfego, senão o MinResponseTime do
+// health check quando ambos o têm. Sem medida comum, ok é false.
+func comparableLatencyMs(a, b ProcessorStatus) (float64, float64, bool) {
+	if a.Health.P95Ms > 0 && b.Health.P95Ms > 0 {
+		return a.Health.P95Ms, b.Health.P95Ms, true
+	}
+
+	return comparableHealthCheckMs(a, b)
+}
+
+// comparableEwmaMs é a comparableLatencyMs com a EWMA no lugar do p95.
+func comparableEwmaMs(a, b ProcessorStatus) (float64, float64, bool) {
+	if a.Health.EwmaMs > 0 && b.Health.EwmaMs > 0 {
+		return a.Health.EwmaMs, b.Health.EwmaMs, true
+	}
+
+	return comparableHealthCheckMs(a, b)
+}
+
+func comparableHealthCheckMs(a, b ProcessorStatus) (float64, float64, bool) {
+	if a.Health.MinResponseTime > 0 && b.Health.MinResponseTime > 0 {
+		return float64(a.Health.MinResponseTime), float64(b.Health.MinResponseTime), true
+	}
+
+	return 0, 0, false
+}
+
+// minSuccessRate evita que uma taxa de erro perto de 100% torne o custo
+// esperado infinito.
+const minSuccessRate = 0.05
+
+// FeeAwareRouting escolhe o processador de menor custo esperado por
+// pagamento: a taxa, cobrada só no sucesso, mais a latência convertida por
+// LatencyCost e multiplicada pelo número esperado de tentativas
+// (1 / taxa de sucesso observada). Empate fica com o de maior prioridade.
+type FeeAwareRouting struct {
+	LatencyCost float64
+}
+
+func (f FeeAwareRouting) Route(_ workers.Message, processors []ProcessorStatus) string {
+	route := RouteNone
+	lowest := 0.0
+
+	for _, processor := range processors {
+		if !processor.usable() {
+			continue
+		}
+
+		cost := f.expectedCost(processor)
+		if route == RouteNone || cost < lowest {
+			route, lowest = processor.Name, cost
+		}
+	}
+
+	return route
+}
+
+func (f FeeAwareRouting) expectedCost(status ProcessorStatus) float64 {
+	attempts := 1 / max(1-status.Health.ErrorRate, minSuccessRate)
+	return status.Fee + f.LatencyCost*status.latencyMs()*attempts
+}
+
+// LatencyBudgetRouting fica no preferido enquanto a EWMA dele não passar de
+// Budget vezes a de outro de pé; acima disso o preferido está de pé, mas lento
+// demais, e o tráfego vai para o que tem a maior folga. As medidas seguem a
+// mesma régua da LatencyAwareRouting (ver comparableEwmaMs).
+type LatencyBudgetRouting struct {
+	Budget float64
+}
+
+func (l LatencyBudgetRouting) Route(_ workers.Message, processors []ProcessorStatus) string {
+	first, ok := preferred(processors)
+	if !ok {
+		return RouteNone
+	}
+
+	if l.Budget <= 0 {
+		return first.Name
+	}
+
+	route, bestRatio := first.Name, l.Budget
+	for _, processor := range processors {
+		if processor.Failing || processor.Name == first.Name {
+			continue
+		}
+
+		firstMs, candidateMs, ok := comparableEwmaMs(first, processor)
+		if !ok {
+			continue
+		}
+
+		if ratio := firstMs / candidateMs; ratio > bestRatio {
+			route, bestRatio = processor.Name, ratio
+		}
+	}
+
+	return route
+}
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Patrignani/patrignani-rinha-backend-go/internal/workers"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/cache"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/clock"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/config"
+)
+
+// Os estados abaixo reproduzem o que ScreeningServiceImp.status monta com a
+// HealthPolicy padrão: degraded e unknown contam como fora, e o disjuntor
+// aberto marca o processador como fora mesmo com a saúde boa.
+func healthy(name string) ProcessorStatus {
+	return ProcessorStatus{Name: name, State: cache.HealthHealthy}
+}
+
+func degraded(name string) ProcessorStatus {
+	return ProcessorStatus{Name: name, State: cache.HealthDegraded, Failing: true}
+}
+
+func failing(name string) ProcessorStatus {
+	return ProcessorStatus{Name: name, State: cache.HealthFailing, Failing: true}
+}
+
+func unknown(name string) ProcessorStatus {
+	return ProcessorStatus{Name: name, State: cache.HealthUnknown, Failing: true}
+}
+
+func breakerOpen(name string) ProcessorStatus {
+	return ProcessorStatus{Name: name, State: cache.HealthHealthy, Failing: true, BreakerOpen: true}
+}
+
+func withFee(s ProcessorStatus, fee float64, weight int) ProcessorStatus {
+	s.Fee, s.Weight = fee, weight
+	return s
+}
+
+func withHealth(s ProcessorStatus, health cache.ProcessorHealth) ProcessorStatus {
+	health.State, health.Failing = s.State, s.Failing
+	s.Health = health
+	return s
+}
+
+func pair(defaultApi, fallbackApi ProcessorStatus) []ProcessorStatus {
+	return []ProcessorStatus{
+		withFee(defaultApi, 0.05, 80),
+		withFee(fallbackApi, 0.15, 20),
+	}
+}
+
+func TestRoutingPolicies(t *testing.T) {
+	fast := cache.ProcessorHealth{P95Ms: 10, EwmaMs: 10, MinResponseTime: 5}
+	slow := cache.ProcessorHealth{P95Ms: 100, EwmaMs: 100, MinResponseTime: 80}
+	opts := RoutingOptions{
+		LatencyMargin: 5 * time.Millisecond,
+		LatencyCost:   0.0001,
+		LatencyBudget: 2,
+		Rand:          fixedRand(0.5),
+	}
+
+	tests := []struct {
+		name       string
+		policy     string
+		opts       *RoutingOptions
+		processors []ProcessorStatus
+		want       string
+	}{
+		// strict
+		{name: "os dois de pé", policy: "strict", processors: pair(healthy("default"), healthy("fallback")), want: "default"},
+		{name: "default degradado", policy: "strict", processors: pair(degraded("default"), healthy("fallback")), want: "fallback"},
+		{name: "default fora", policy: "strict", processors: pair(failing("default"), healthy("fallback")), want: "fallback"},
+		{name: "default desconhecido", policy: "strict", processors: pair(unknown("default"), healthy("fallback")), want: "fallback"},
+		{name: "disjuntor do default aberto", policy: "strict", processors: pair(breakerOpen("default"), healthy("fallback")), want: "fallback"},
+		{name: "os dois fora", policy: "strict", processors: pair(failing("default"), unknown("fallback")), want: RouteNone},
+
+		// weighted
+		{name: "sorteio dentro da fatia do default", policy: "weighted", processors: pair(healthy("default"), healthy("fallback")), want: "default"},
+		{name: "sorteio dentro da fatia do fallback", policy: "weighted", opts: &RoutingOptions{Rand: fixedRand(0.9)}, processors: pair(healthy("default"), healthy("fallback")), want: "fallback"},
+		{name: "default degradado", policy: "weighted", processors: pair(degraded("default"), healthy("fallback")), want: "fallback"},
+		{name: "fallback fora", policy: "weighted", opts: &RoutingOptions{Rand: fixedRand(0.9)}, processors: pair(healthy("default"), failing("fallback")), want: "default"},
+		{name: "disjuntor do fallback aberto", policy: "weighted", opts: &RoutingOptions{Rand: fixedRand(0.9)}, processors: pair(healthy("default"), breakerOpen("fallback")), want: "default"},
+		{name: "sem peso cai para a strict", policy: "weighted", processors: []ProcessorStatus{healthy("default"), healthy("fallback")}, want: "default"},
+		{name: "os dois desconhecidos", policy: "weighted", processors: pair(unknown("default"), unknown("fallback")), want: RouteNone},
+
+		// cost
+		{name: "fica no mais barato", policy: "cost", processors: pair(healthy("default"), healthy("fallback")), want: "default"},
+		{name: "mais barato degradado ainda serve", policy: "cost", processors: pair(degraded("default"), healthy("fallback")), want: "default"},
+		{name: "mais barato fora", policy: "cost", processors: pair(failing("default"), healthy("fallback")), want: "fallback"},
+		{name: "mais barato desconhecido", policy: "cost", processors: pair(unknown("default"), healthy("fallback")), want: "fallback"},
+		{name: "disjuntor do mais barato aberto", policy: "cost", processors: pair(breakerOpen("default"), healthy("fallback")), want: "fallback"},
+		{name: "os dois fora", policy: "cost", processors: pair(failing("default"), breakerOpen("fallback")), want: RouteNone},
+
+		// latency
+		{name: "fallback mais rápido além da margem", policy: "latency", processors: pair(withHealth(healthy("default"), slow), withHealth(healthy("fallback"), fast)), want: "fallback"},
+		{name: "diferença dentro da margem", policy: "latency", processors: pair(withHealth(healthy("default"), cache.ProcessorHealth{P95Ms: 14}), withHealth(healthy("fallback"), cache.ProcessorHealth{P95Ms: 10})), want: "default"},
+		{name: "fallback sem medida", policy: "latency", processors: pair(withHealth(healthy("default"), slow), healthy("fallback")), want: "default"},
+		{name: "fallback com MinResponseTime zero", policy: "latency", processors: pair(withHealth(healthy("default"), slow), withHealth(healthy("fallback"), cache.ProcessorHealth{})), want: "default"},
+		{name: "p95 contra MinResponseTime não compara", policy: "latency", processors: pair(withHealth(healthy("default"), cache.ProcessorHealth{P95Ms: 100}), withHealth(healthy("fallback"), cache.ProcessorHealth{MinResponseTime: 5})), want: "default"},
+		{name: "compara MinResponseTime sem tráfego", policy: "latency", processors: pair(withHealth(healthy("default"), cache.ProcessorHealth{MinResponseTime: 100}), withHealth(healthy("fallback"), cache.ProcessorHealth{MinResponseTime: 5})), want: "fallback"},
+		{name: "default degradado", policy: "latency", processors: pair(withHealth(degraded("default"), slow), withHealth(healthy("fallback"), slow)), want: "fallback"},
+		{name: "fallback rápido mas fora", policy: "latency", processors: pair(withHealth(healthy("default"), slow), withHealth(failing("fallback"), fast)), want: "default"},
+		{name: "fallback rápido mas desconhecido", policy: "latency", processors: pair(withHealth(healthy("default"), slow), withHealth(unknown("fallback"), fast)), want: "default"},
+		{name: "fallback rápido com disjuntor aberto", policy: "latency", processors: pair(withHealth(healthy("default"), slow), withHealth(breakerOpen("fallback"), fast)), want: "default"},
+		{name: "os dois fora", policy: "latency", processors: pair(failing("default"), failing("fallback")), want: RouteNone},
+
+		// fee
+		{name: "mesma latência fica na menor taxa", policy: "fee", processors: pair(withHealth(healthy("default"), slow), withHealth(healthy("fallback"), slow)), want: "default"},
+		{name: "erro alto no default compensa a taxa", policy: "fee", processors: pair(withHealth(healthy("default"), cache.ProcessorHealth{P95Ms: 100, ErrorRate: 0.95}), withHealth(healthy("fallback"), fast)), want: "fallback"},
+		{name: "default degradado ainda serve", policy: "fee", processors: pair(withHealth(degraded("default"), slow), withHealth(healthy("fallback"), slow)), want: "default"},
+		{name: "default fora", policy: "fee", processors: pair(failing("default"), healthy("fallback")), want: "fallback"},
+		{name: "default desconhecido", policy: "fee", processors: pair(unknown("default"), healthy("fallback")), want: "fallback"},
+		{name: "disjuntor do default aberto", policy: "fee", processors: pair(breakerOpen("default"), degraded("fallback")), want: "fallback"},
+		{name: "os dois fora", policy: "fee", processors: pair(failing("default"), unknown("fallback")), want: RouteNone},
+
+		// latency-budget
+		{name: "default dentro do orçamento", policy: "latency-budget", processors: pair(withHealth(healthy("default"), cache.ProcessorHealth{EwmaMs: 15}), withHealth(healthy("fallback"), fast)), want: "default"},
+		{name: "default acima do orçamento", policy: "latency-budget", processors: pair(withHealth(healthy("default"), slow), withHealth(healthy("fallback"), fast)), want: "fallback"},
+		{name: "fallback sem medida", policy: "latency-budget", processors: pair(withHealth(healthy("default"), slow), healthy("fallback")), want: "default"},
+		{name: "EWMA contra MinResponseTime não compara", policy: "latency-budget", processors: pair(withHealth(healthy("default"), cache.ProcessorHealth{EwmaMs: 100}), withHealth(healthy("fallback"), cache.ProcessorHealth{MinResponseTime: 5})), want: "default"},
+		{name: "compara MinResponseTime sem tráfego", policy: "latency-budget", processors: pair(withHealth(healthy("default"), cache.ProcessorHealth{MinResponseTime: 100}), withHealth(healthy("fallback"), cache.ProcessorHealth{MinResponseTime: 5})), want: "fallback"},
+		{name: "default degradado", policy: "latency-budget", processors: pair(degraded("default"), healthy("fallback")), want: "fallback"},
+		{name: "fallback rápido mas fora", policy: "latency-budget", processors: pair(withHealth(healthy("default"), slow), withHealth(failing("fallback"), fast)), want: "default"},
+		{name: "fallback rápido mas desconhecido", policy: "latency-budget", processors: pair(withHealth(healthy("default"), slow), withHealth(unknown("fallback"), fast)), want: "default"},
+		{name: "disjuntor do default aberto", policy: "latency-budget", processors: pair(breakerOpen("default"), healthy("fallback")), want: "fallback"},
+		{name: "os dois fora", policy: "latency-budget", processors: pair(breakerOpen("default"), failing("fallback")), want: RouteNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy+"/"+tt.name, func(t *testing.T) {
+			routingOpts := opts
+			if tt.opts != nil {
+				routingOpts = *tt.opts
+			}
+
+			policy, err := NewRoutingPolicy(tt.policy, routingOpts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := policy.Route(workers.Message{}, tt.processors); got != tt.want {
+				t.Errorf("Route() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoutingPoliciesWithManyProcessors(t *testing.T) {
+	processors := []ProcessorStatus{
+		withFee(withHealth(failing("primeiro"), cache.ProcessorHealth{P95Ms: 5}), 0.01, 50),
+		withFee(withHealth(healthy("segundo"), cache.ProcessorHealth{P95Ms: 100, EwmaMs: 100}), 0.10, 25),
+		withFee(withHealth(healthy("terceiro"), cache.ProcessorHealth{P95Ms: 10, EwmaMs: 10}), 0.05, 25),
+	}
+
+	tests := []struct {
+		policy string
+		rand   float64
+		want   string
+	}{
+		{policy: "strict", want: "segundo"},
+		{policy: "weighted", rand: 0.4, want: "segundo"},
+		{policy: "weighted", rand: 0.6, want: "terceiro"},
+		{policy: "cost", want: "terceiro"},
+		{policy: "latency", want: "terceiro"},
+		{policy: "fee", want: "terceiro"},
+		{policy: "latency-budget", want: "terceiro"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			policy, err := NewRoutingPolicy(tt.policy, RoutingOptions{
+				LatencyMargin: 5 * time.Millisecond,
+				LatencyBudget: 2,
+				Rand:          fixedRand(tt.rand),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := policy.Route(workers.Message{}, processors); got != tt.want {
+				t.Errorf("Route() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Depois de desviar do default lento, o tráfego precisa voltar quando o
+// health check mostrar que ele se recuperou, mesmo sem chamadas novas a ele.
+func TestLatencyRoutingReturnsToRecoveredDefault(t *testing.T) {
+	for _, name := range []string{"latency", "latency-budget"} {
+		t.Run(name, func(t *testing.T) {
+			clk := clock.NewFake(time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC))
+			memoryCache := cache.NewCostRoutingThresholdCache(time.Second)
+			screening := &ScreeningServiceImp{
+				memoryCache:  memoryCache,
+				healthPolicy: HealthPolicy{ObservationTTL: time.Second},
+			}
+
+			routing, err := NewRoutingPolicy(name, RoutingOptions{LatencyMargin: 5 * time.Millisecond, LatencyBudget: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defaultApi := config.Processor{Name: "default", Fee: 0.05}
+			fallbackApi := config.Processor{Name: "fallback", Fee: 0.15}
+			route := func() string {
+				now := clk.Now()
+				return routing.Route(workers.Message{}, []ProcessorStatus{
+					screening.status(defaultApi, now),
+					screening.status(fallbackApi, now),
+				})
+			}
+
+			observe := func(processor string, latency time.Duration) {
+				for range 20 {
+					memoryCache.Observe(processor, latency, nil, clk.Now())
+				}
+			}
+
+			memoryCache.SetHealthCheck("default", cache.HealthHealthy, 100, clk.Now())
+			memoryCache.SetHealthCheck("fallback", cache.HealthHealthy, 10, clk.Now())
+			observe("default", 100*time.Millisecond)
+			if got := route(); got != "fallback" {
+				t.Fatalf("default lento: Route() = %q, want fallback", got)
+			}
+
+			observe("fallback", 10*time.Millisecond)
+			if got := route(); got != "fallback" {
+				t.Fatalf("tráfego no fallback: Route() = %q, want fallback", got)
+			}
+
+			// O default se recupera; o fallback continua recebendo tráfego.
+			clk.Advance(2 * time.Second)
+			memoryCache.SetHealthCheck("default", cache.HealthHealthy, 5, clk.Now())
+			memoryCache.SetHealthCheck("fallback", cache.HealthHealthy, 10, clk.Now())
+			observe("fallback", 10*time.Millisecond)
+			if got := route(); got != "default" {
+				t.Fatalf("default recuperado: Route() = %q, want default", got)
+			}
+
+			// As chamadas novas ao default recomeçam a janela em vez de somar
+			// às lentas de antes.
+			observe("default", 5*time.Millisecond)
+			if got := route(); got != "default" {
+				t.Errorf("tráfego de volta no default: Route() = %q, want default", got)
+			}
+		})
+	}
+}
+
+func TestNewRoutingPolicyUnknown(t *testing.T) {
+	if _, err := NewRoutingPolicy("round-robin", RoutingOptions{}); err == nil {
+		t.Error("NewRoutingPolicy() aceitou uma política desconhecida")
+	}
+}
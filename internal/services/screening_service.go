@@ -2,116 +2,132 @@ package services
 
 import (
 	"context"
-	"math/rand"
+	"fmt"
+	"time"
 
 	"github.com/Patrignani/patrignani-rinha-backend-go/internal/workers"
 	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/cache"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/circuitbreaker"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/clock"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/config"
 )
 
 type ScreeningService interface {
 	Redirect(ctx context.Context, msg workers.Message) error
 }
 
+// ProcessorQueue liga um processador configurado à fila consumida por ele.
+type ProcessorQueue struct {
+	config.Processor
+	Worker workers.QueueWorker
+}
+
+// processors vem em ordem de prioridade, como em config.Env.Processors.
 type ScreeningServiceImp struct {
-	memoryCache       cache.AtomicCache
-	highPriorityQueue workers.QueueWorker
-	lowPriorityQueue  workers.QueueWorker
-	waitingRoom       workers.QueueWorker
+	memoryCache    cache.AtomicCache
+	processors     []ProcessorQueue
+	waitingRoom    workers.QueueWorker
+	urgentQueue    workers.QueueWorker
+	deadLetters    workers.DeadLetterStore
+	retryPolicy    RetryPolicy
+	deadlinePolicy DeadlinePolicy
+	healthPolicy   HealthPolicy
+	breakers       map[string]circuitbreaker.CircuitBreaker
+	routingPolicy  RoutingPolicy
+	clock          clock.Clock
 }
 
-func NewScreeningService(memoryCache cache.AtomicCache, highPriorityQueue workers.QueueWorker, lowPriorityQueue workers.QueueWorker, waitingRoom workers.QueueWorker) ScreeningService {
+func NewScreeningService(memoryCache cache.AtomicCache, processors []ProcessorQueue, waitingRoom workers.QueueWorker, urgentQueue workers.QueueWorker, deadLetters workers.DeadLetterStore, retryPolicy RetryPolicy, deadlinePolicy DeadlinePolicy, healthPolicy HealthPolicy, breakers map[string]circuitbreaker.CircuitBreaker, routingPolicy RoutingPolicy, clk clock.Clock) ScreeningService {
 	return &ScreeningServiceImp{
-		memoryCache:       memoryCache,
-		highPriorityQueue: highPriorityQueue,
-		lowPriorityQueue:  lowPriorityQueue,
-		waitingRoom:       waitingRoom,
+		memoryCache:    memoryCache,
+		processors:     processors,
+		waitingRoom:    waitingRoom,
+		urgentQueue:    urgentQueue,
+		deadLetters:    deadLetters,
+		retryPolicy:    retryPolicy,
+		deadlinePolicy: deadlinePolicy,
+		healthPolicy:   healthPolicy,
+		breakers:       breakers,
+		routingPolicy:  routingPolicy,
+		clock:          clk,
 	}
 }
 
 func (s *ScreeningServiceImp) Redirect(ctx context.Context, msg workers.Message) error {
-	defaultStatusFail := s.memoryCache.GetHealthDeafultApi()
-	fallbackStatusFail := s.memoryCache.GetHealthFallbackApi()
-
-	if !defaultStatusFail {
-		s.lowPriorityQueue.Send(msg)
-		return nil
+	// Pagamento já confirmado só precisa ser gravado: volta para a fila de
+	// quem confirmou, esteja ele de pé ou não.
+	if msg.ConfirmedBy != "" {
+		return s.send(msg.ConfirmedBy, msg)
 	}
 
-	if !fallbackStatusFail {
-		s.highPriorityQueue.Send(msg)
-		return nil
-	}
-
-	if msg.ReprocessedHowManyTimes > 0 {
-		baseChance := 30
-		increment := 10
-		chance := baseChance + increment*msg.ReprocessedHowManyTimes
-
-		if chance > 80 {
-			chance = 80
-		}
+	now := s.clock.Now().UTC()
 
-		randValue := rand.Intn(100)
+	statuses := make([]ProcessorStatus, len(s.processors))
+	for i, processor := range s.processors {
+		statuses[i] = s.status(processor.Processor, now)
+	}
 
-		if randValue < chance {
-			s.calcRedirect(msg, 50)
+	if s.deadlinePolicy.Expired(msg, now) {
+		switch s.deadlinePolicy.Action {
+		case DeadlineUrgent:
+			return s.urgentQueue.Send(msg)
+		case DeadlineAnyProcessor:
+			if route := (StrictRouting{}).Route(msg, statuses); route != RouteNone {
+				return s.send(route, msg)
+			}
+			return s.processors[0].Worker.Send(msg)
+		case DeadlineExpire:
+			s.deadLetters.Add(msg, "expired")
 			return nil
 		}
 	}
 
-	s.waitingRoom.Send(msg)
-
-	return nil
-}
-
-// func (s *ScreeningServiceImp) Redirect(ctx context.Context, msg workers.Message) error {
-// 	defaultStatusFail := s.memoryCache.GetHealthDeafultApi()
-// 	fallbackStatusFail := s.memoryCache.GetHealthFallbackApi()
-
-// 	if !defaultStatusFail && !fallbackStatusFail {
-// 		s.calcRedirect(msg, config.Env.CalcRedirect)
-// 		return nil
-// 	}
-
-// 	if defaultStatusFail && !fallbackStatusFail {
-// 		s.highPriorityQueue.Send(msg)
-// 		return nil
-// 	}
-
-// 	if !defaultStatusFail && fallbackStatusFail {
-// 		s.lowPriorityQueue.Send(msg)
-// 		return nil
-// 	}
-
-// 	if msg.ReprocessedHowManyTimes > 0 {
-// 		baseChance := 30
-// 		increment := 10
-// 		chance := baseChance + increment*msg.ReprocessedHowManyTimes
+	if route := s.routingPolicy.Route(msg, statuses); route != RouteNone {
+		return s.send(route, msg)
+	}
 
-// 		if chance > 80 {
-// 			chance = 80
-// 		}
+	if s.retryPolicy.ShouldRetry(msg) {
+		// A sonda vai para um dos processadores de menor prioridade, escolhido
+		// ao acaso; sem sonda, tenta o preferido.
+		if len(s.processors) > 1 && s.retryPolicy.ProbeFallback() {
+			probe := 1 + int(s.retryPolicy.random()*float64(len(s.processors)-1))
+			return s.processors[probe].Worker.Send(msg)
+		}
 
-// 		randValue := rand.Intn(100)
+		return s.processors[0].Worker.Send(msg)
+	}
 
-// 		if randValue < chance {
-// 			s.calcRedirect(msg, 50)
-// 			return nil
-// 		}
-// 	}
+	return s.waitingRoom.Send(msg)
+}
 
-// 	s.waitingRoom.Send(msg)
+func (s *ScreeningServiceImp) send(processor string, msg workers.Message) error {
+	for _, p := range s.processors {
+		if p.Name == processor {
+			return p.Worker.Send(msg)
+		}
+	}
 
-// 	return nil
-// }
+	return fmt.Errorf("processador desconhecido: %s", processor)
+}
 
-func (s *ScreeningServiceImp) calcRedirect(msg workers.Message, chance int) {
-	randValue := rand.Intn(100)
+// status monta a visão do processador para o roteamento: fora quando o
+// disjuntor dele não aceita chamadas ou quando a HealthPolicy o dá como fora,
+// e só com as medidas de tráfego ainda recentes.
+func (s *ScreeningServiceImp) status(processor config.Processor, now time.Time) ProcessorStatus {
+	health := s.memoryCache.GetProcessorHealth(processor.Name)
+	status := ProcessorStatus{
+		Name:    processor.Name,
+		Fee:     processor.Fee,
+		Weight:  processor.Weight,
+		State:   s.healthPolicy.State(health, now),
+		Failing: s.healthPolicy.Failing(health, now),
+		Health:  s.healthPolicy.Traffic(health, now),
+	}
 
-	if randValue < chance {
-		s.highPriorityQueue.Send(msg)
-		return
+	if breaker, ok := s.breakers[processor.Name]; ok && !breaker.Available() {
+		status.BreakerOpen = true
+		status.Failing = true
 	}
 
-	s.lowPriorityQueue.Send(msg)
+	return status
 }
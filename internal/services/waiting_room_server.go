@@ -3,10 +3,9 @@ package services
 import (
 	"context"
 	"log"
-	"time"
 
 	"github.com/Patrignani/patrignani-rinha-backend-go/internal/workers"
-	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/config"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/clock"
 )
 
 type WaitingRoomServer interface {
@@ -14,19 +13,50 @@ type WaitingRoomServer interface {
 }
 
 type WaitingRoomServerImp struct {
-	screeningQueue workers.QueueWorker
+	scheduler   workers.DelayedQueue
+	deadLetters workers.DeadLetterStore
+	policy      DeadLetterPolicy
+	retryPolicy RetryPolicy
+	clock       clock.Clock
 }
 
-func NewWaitingRoomServer(screeningQueue workers.QueueWorker) WaitingRoomServer {
+func NewWaitingRoomServer(scheduler workers.DelayedQueue, deadLetters workers.DeadLetterStore, policy DeadLetterPolicy, retryPolicy RetryPolicy, clk clock.Clock) WaitingRoomServer {
 	return &WaitingRoomServerImp{
-		screeningQueue: screeningQueue,
+		scheduler:   scheduler,
+		deadLetters: deadLetters,
+		policy:      policy,
+		retryPolicy: retryPolicy,
+		clock:       clk,
 	}
 }
 
 func (w *WaitingRoomServerImp) Delay(ctx context.Context, msg workers.Message) error {
-	time.Sleep(config.Env.WaitingRoomSleepTime)
-	msg.ReprocessedHowManyTimes++
-	log.Printf("WaitingRoom msg: %s, ReprocessedHowManyTimes: %d", msg.CorrelationId, msg.ReprocessedHowManyTimes)
-	w.screeningQueue.Send(msg)
+	// Um pagamento confirmado não vai para dead letter: falta só gravá-lo.
+	if msg.ConfirmedBy != "" {
+		w.scheduler.SendAfter(msg, 0)
+		return nil
+	}
+
+	// Uma recusa do disjuntor não chamou o processador: conta só a idade, e
+	// não o número de tentativas.
+	policy := w.policy
+	if msg.BreakerRejected {
+		policy.MaxAttempts = 0
+	}
+
+	if reason, exhausted := policy.Exhausted(msg, w.clock.Now().UTC()); exhausted {
+		log.Printf("WaitingRoom msg: %s enviada para dead letter: %s", msg.CorrelationId, reason)
+		w.deadLetters.Add(msg, reason)
+		return nil
+	}
+
+	delay := w.retryPolicy.Delay(msg)
+	msg.RetryDelay = delay
+	if !msg.BreakerRejected {
+		msg.ReprocessedHowManyTimes++
+	}
+	msg.BreakerRejected = false
+	log.Printf("WaitingRoom msg: %s, ReprocessedHowManyTimes: %d, delay: %v", msg.CorrelationId, msg.ReprocessedHowManyTimes, delay)
+	w.scheduler.SendAfter(msg, delay)
 	return nil
 }
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Patrignani/patrignani-rinha-backend-go/internal/testutil"
+	"github.com/Patrignani/patrignani-rinha-backend-go/internal/workers"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/clock"
+)
+
+func newTestWaitingRoom(t *testing.T, policy DeadLetterPolicy) (*clock.Fake, WaitingRoomServer, workers.QueueWorker, workers.DeadLetterStore) {
+	t.Helper()
+
+	fake := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	screening := workers.NewQueueWorker(workers.QueueOptions{Buffer: 10})
+	scheduler := workers.NewDelayedQueue(screening, nil, nil, fake)
+	deadLetters := workers.NewDeadLetterStore(fake)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go scheduler.Run(ctx)
+
+	retryPolicy := RetryPolicy{BaseDelay: 200 * time.Millisecond, Multiplier: 2, Jitter: JitterNone}
+	server := NewWaitingRoomServer(scheduler, deadLetters, policy, retryPolicy, fake)
+
+	return fake, server, screening, deadLetters
+}
+
+func TestWaitingRoomRescreensAfterDelay(t *testing.T) {
+	fake, server, screening, _ := newTestWaitingRoom(t, DeadLetterPolicy{})
+
+	msg := workers.Message{CorrelationId: "a", ReprocessedHowManyTimes: 1, EnqueueAt: fake.Now()}
+	if err := server.Delay(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Segunda tentativa: 200ms * 2^1.
+	fake.Advance(399 * time.Millisecond)
+	testutil.Stays(t, func() bool { fake.Advance(0); return screening.Len() == 0 })
+
+	fake.Advance(time.Millisecond)
+	testutil.Eventually(t, func() bool { fake.Advance(0); return screening.Len() == 1 })
+
+	got := screening.Snapshot()[0]
+	if got.ReprocessedHowManyTimes != 2 {
+		t.Errorf("ReprocessedHowManyTimes = %d, want 2", got.ReprocessedHowManyTimes)
+	}
+	if got.RetryDelay != 400*time.Millisecond {
+		t.Errorf("RetryDelay = %v, want 400ms", got.RetryDelay)
+	}
+}
+
+func TestWaitingRoomDeadLettersByAge(t *testing.T) {
+	fake, server, screening, deadLetters := newTestWaitingRoom(t, DeadLetterPolicy{MaxAge: time.Minute})
+
+	msg := workers.Message{CorrelationId: "a", EnqueueAt: fake.Now()}
+	fake.Advance(time.Minute + time.Millisecond)
+
+	if err := server.Delay(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := deadLetters.Get("a"); !ok {
+		t.Fatal("mensagem vencida não foi para dead letter")
+	}
+
+	fake.Advance(time.Hour)
+	testutil.Stays(t, func() bool { fake.Advance(0); return screening.Len() == 0 })
+}
+
+func TestWaitingRoomDeadLettersByAttempts(t *testing.T) {
+	_, server, _, deadLetters := newTestWaitingRoom(t, DeadLetterPolicy{MaxAttempts: 3})
+
+	if err := server.Delay(context.Background(), workers.Message{CorrelationId: "a", ReprocessedHowManyTimes: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := deadLetters.Get("a"); !ok {
+		t.Fatal("mensagem sem tentativas não foi para dead letter")
+	}
+}
+
+func TestWaitingRoomDoesNotCountBreakerRejections(t *testing.T) {
+	fake, server, screening, deadLetters := newTestWaitingRoom(t, DeadLetterPolicy{MaxAttempts: 3})
+
+	msg := workers.Message{CorrelationId: "a", ReprocessedHowManyTimes: 3, BreakerRejected: true}
+	if err := server.Delay(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := deadLetters.Get("a"); ok {
+		t.Fatal("recusa do disjuntor levou a mensagem para dead letter")
+	}
+
+	// Sem contar a recusa, o atraso continua o da terceira tentativa.
+	fake.Advance(1600 * time.Millisecond)
+	testutil.Eventually(t, func() bool { fake.Advance(0); return screening.Len() == 1 })
+
+	got := screening.Snapshot()[0]
+	if got.ReprocessedHowManyTimes != 3 || got.BreakerRejected {
+		t.Errorf("ReprocessedHowManyTimes = %d, BreakerRejected = %v, want 3 e false", got.ReprocessedHowManyTimes, got.BreakerRejected)
+	}
+}
+
+func TestWaitingRoomNeverDeadLettersConfirmedPayments(t *testing.T) {
+	fake, server, screening, deadLetters := newTestWaitingRoom(t, DeadLetterPolicy{MaxAttempts: 3})
+
+	msg := workers.Message{CorrelationId: "a", ReprocessedHowManyTimes: 3, ConfirmedBy: "default"}
+	if err := server.Delay(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := deadLetters.Get("a"); ok {
+		t.Fatal("pagamento confirmado foi para dead letter")
+	}
+
+	testutil.Eventually(t, func() bool { fake.Advance(0); return screening.Len() == 1 })
+}
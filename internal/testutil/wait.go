@@ -0,0 +1,33 @@
+// Package testutil reúne auxiliares usados pelos testes de mais de um pacote.
+package testutil
+
+import (
+	"testing"
+	"time"
+)
+
+// Eventually repete cond até ela valer, falhando depois de um segundo.
+func Eventually(t testing.TB, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condição não foi atingida")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Stays verifica que cond continua valendo por um tempo curto.
+func Stays(t testing.TB, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(20 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		if !cond() {
+			t.Fatal("condição deixou de valer")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,86 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	adaptiveIncrease = 1
+	adaptiveDecrease = 0.75
+)
+
+type LatencyObserver interface {
+	Observe(latency time.Duration, err error)
+}
+
+type AdaptiveOptions struct {
+	Min            int
+	Max            int
+	LatencyTarget  time.Duration
+	ErrorThreshold float64
+}
+
+// AdaptiveController ajusta o número de workers de uma fila no estilo AIMD:
+// soma um worker quando latência e erros estão dentro do alvo e corta uma
+// fração quando qualquer um deles estoura.
+type AdaptiveController struct {
+	name    string
+	queue   QueueWorker
+	opts    AdaptiveOptions
+	count   int
+	errors  int
+	latency time.Duration
+	mu      sync.Mutex
+}
+
+func NewAdaptiveController(name string, queue QueueWorker, opts AdaptiveOptions) *AdaptiveController {
+	return &AdaptiveController{
+		name:  name,
+		queue: queue,
+		opts:  opts,
+	}
+}
+
+func (a *AdaptiveController) Observe(latency time.Duration, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.count++
+	a.latency += latency
+	if err != nil {
+		a.errors++
+	}
+}
+
+func (a *AdaptiveController) Adjust(ctx context.Context) error {
+	a.mu.Lock()
+	count, errors, latency := a.count, a.errors, a.latency
+	a.count, a.errors, a.latency = 0, 0, 0
+	a.mu.Unlock()
+
+	if count == 0 {
+		return nil
+	}
+
+	avgLatency := latency / time.Duration(count)
+	errorRate := float64(errors) / float64(count)
+
+	current := a.queue.Workers()
+	next := current + adaptiveIncrease
+	if avgLatency > a.opts.LatencyTarget || errorRate > a.opts.ErrorThreshold {
+		next = int(float64(current) * adaptiveDecrease)
+	}
+
+	next = max(a.opts.Min, min(a.opts.Max, next))
+	if next == current {
+		return nil
+	}
+
+	log.Printf("[%s] Ajustando workers %d -> %d (latência média %v, erros %.0f%%)", a.name, current, next, avgLatency, errorRate*100)
+	a.queue.SetWorkers(next)
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/clock"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/metrics"
+)
+
+// consumer reúne o que as implementações de QueueWorker compartilham ao
+// processar mensagens: o limite de workers, os contadores, o histograma de
+// espera na fila, o isolamento de panics e o relógio (nil usa clock.Real).
+type consumer struct {
+	counters *queueCounters
+	limiter  *Limiter
+	guard    *panicGuard
+	latency  *metrics.Histogram
+	clock    clock.Clock
+}
+
+func newConsumer(maxPanics int, quarantine DeadLetterStore, latency *metrics.Histogram, clk clock.Clock) *consumer {
+	if clk == nil {
+		clk = clock.Real
+	}
+
+	return &consumer{
+		counters: newQueueCounters(),
+		limiter:  NewLimiter(1),
+		guard:    newPanicGuard(maxPanics, quarantine),
+		latency:  latency,
+		clock:    clk,
+	}
+}
+
+func (c *consumer) Workers() int {
+	return c.limiter.Limit()
+}
+
+func (c *consumer) SetWorkers(workers int) {
+	c.limiter.SetLimit(workers)
+}
+
+// dispatch executa process numa goroutine, com a vaga do limiter já reservada
+// por quem chama. resend devolve a mensagem à fila depois de um panic e done
+// recebe o resultado final.
+func (c *consumer) dispatch(ctx context.Context, wg *sync.WaitGroup, msg Message, process func(context.Context, Message) error, resend func(Message) error, done func(Message, error)) {
+	wg.Add(1)
+	c.counters.start(msg)
+
+	if !msg.queuedAt.IsZero() {
+		c.latency.Record(c.clock.Since(msg.queuedAt))
+	}
+
+	go func() {
+		defer wg.Done()
+		defer c.limiter.Release()
+
+		retry, err := c.guard.run(ctx, msg, process)
+		c.counters.finish(msg, err)
+
+		if retry {
+			if err := resend(msg); err != nil {
+				log.Printf("Erro ao reenfileirar mensagem %s: %v", msg.CorrelationId, err)
+			}
+		}
+
+		if err != nil {
+			fmt.Printf("Erro ao processar mensagem %s: %v\n", msg.CorrelationId, err)
+		}
+
+		done(msg, err)
+	}()
+}
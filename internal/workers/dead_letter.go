@@ -0,0 +1,135 @@
+package workers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/clock"
+)
+
+type DeadLetter struct {
+	Message Message   `json:"message"`
+	Reason  string    `json:"reason"`
+	DeadAt  time.Time `json:"deadAt"`
+}
+
+type DeadLetterStore interface {
+	Add(msg Message, reason string)
+	List() []DeadLetter
+	Get(correlationId string) (DeadLetter, bool)
+	Remove(correlationId string) (DeadLetter, bool)
+}
+
+// DeadLetterStoreImp guarda as dead letters em memória e, com path, regrava o
+// arquivo inteiro a cada mudança: elas são poucas e precisam sobreviver a um
+// reinício, já que a mensagem sai do log e das filas quando vira dead letter.
+type DeadLetterStoreImp struct {
+	letters map[string]DeadLetter
+	path    string
+	clock   clock.Clock
+	mu      sync.RWMutex
+}
+
+func NewDeadLetterStore(clk clock.Clock) DeadLetterStore {
+	return &DeadLetterStoreImp{
+		letters: map[string]DeadLetter{},
+		clock:   clk,
+	}
+}
+
+// NewFileDeadLetterStore carrega as dead letters gravadas em path, se houver,
+// e mantém o arquivo atualizado.
+func NewFileDeadLetterStore(path string, clk clock.Clock) (DeadLetterStore, error) {
+	d := &DeadLetterStoreImp{
+		letters: map[string]DeadLetter{},
+		path:    path,
+		clock:   clk,
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ler dead letters: %w", err)
+	}
+
+	var letters []DeadLetter
+	if err := json.Unmarshal(data, &letters); err != nil {
+		return nil, fmt.Errorf("erro desserializar dead letters: %w", err)
+	}
+
+	for _, letter := range letters {
+		d.letters[letter.Message.CorrelationId] = letter
+	}
+
+	return d, nil
+}
+
+func (d *DeadLetterStoreImp) Add(msg Message, reason string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.letters[msg.CorrelationId] = DeadLetter{
+		Message: msg,
+		Reason:  reason,
+		DeadAt:  d.clock.Now().UTC(),
+	}
+
+	d.persist()
+}
+
+func (d *DeadLetterStoreImp) List() []DeadLetter {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.list()
+}
+
+func (d *DeadLetterStoreImp) Get(correlationId string) (DeadLetter, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	letter, ok := d.letters[correlationId]
+	return letter, ok
+}
+
+func (d *DeadLetterStoreImp) Remove(correlationId string) (DeadLetter, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	letter, ok := d.letters[correlationId]
+	if ok {
+		delete(d.letters, correlationId)
+		d.persist()
+	}
+
+	return letter, ok
+}
+
+func (d *DeadLetterStoreImp) list() []DeadLetter {
+	letters := make([]DeadLetter, 0, len(d.letters))
+	for _, letter := range d.letters {
+		letters = append(letters, letter)
+	}
+
+	sort.Slice(letters, func(i, j int) bool { return letters[i].DeadAt.Before(letters[j].DeadAt) })
+
+	return letters
+}
+
+// persist é chamado com o lock de escrita; sem path não faz nada.
+func (d *DeadLetterStoreImp) persist() {
+	if d.path == "" {
+		return
+	}
+
+	if err := writeJSONFile(d.path, d.list()); err != nil {
+		log.Printf("Erro ao gravar dead letters: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package workers
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/clock"
+)
+
+func TestFileDeadLetterStoreSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead-letters.json")
+	fake := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	store, err := NewFileDeadLetterStore(path, fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.Add(Message{CorrelationId: "a"}, "max attempts")
+	fake.Advance(time.Second)
+	store.Add(Message{CorrelationId: "b"}, "expired")
+	store.Remove("a")
+
+	reopened, err := NewFileDeadLetterStore(path, fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := reopened.Get("a"); ok {
+		t.Error("dead letter removida voltou depois do reinício")
+	}
+
+	letter, ok := reopened.Get("b")
+	if !ok {
+		t.Fatal("dead letter perdida no reinício")
+	}
+
+	if letter.Reason != "expired" || !letter.DeadAt.Equal(fake.Now()) {
+		t.Errorf("dead letter = %+v, want reason expired e DeadAt %v", letter, fake.Now())
+	}
+}
@@ -0,0 +1,164 @@
+package workers
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/clock"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/metrics"
+)
+
+const redeliveryDelay = 100 * time.Millisecond
+
+type DelayedQueue interface {
+	SendAfter(msg Message, delay time.Duration)
+	Run(ctx context.Context)
+	Len() int
+	Snapshot() []Message
+}
+
+type delayedItem struct {
+	msg   Message
+	dueAt time.Time
+}
+
+type delayHeap []delayedItem
+
+func (h delayHeap) Len() int           { return len(h) }
+func (h delayHeap) Less(i, j int) bool { return h[i].dueAt.Before(h[j].dueAt) }
+func (h delayHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *delayHeap) Push(x any)        { *h = append(*h, x.(delayedItem)) }
+func (h *delayHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// DelayedQueueImp guarda as mensagens num heap ordenado pelo horário de
+// entrega e usa uma única goroutine para devolvê-las à fila de destino,
+// sem manter um worker parado em time.Sleep por mensagem. latency mede o
+// tempo entre o agendamento e a entrega, já incluindo o atraso pedido.
+type DelayedQueueImp struct {
+	target  QueueWorker
+	log     MessageLog
+	latency *metrics.Histogram
+	clock   clock.Clock
+	items   delayHeap
+	wake    chan struct{}
+	mu      sync.Mutex
+}
+
+func NewDelayedQueue(target QueueWorker, messageLog MessageLog, latency *metrics.Histogram, clk clock.Clock) DelayedQueue {
+	return &DelayedQueueImp{
+		target:  target,
+		log:     messageLog,
+		latency: latency,
+		clock:   clk,
+		items:   delayHeap{},
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+func (d *DelayedQueueImp) SendAfter(msg Message, delay time.Duration) {
+	if d.log != nil {
+		seq, err := d.log.Append(msg)
+		if err != nil {
+			log.Printf("Erro ao gravar mensagem %s no log: %v", msg.CorrelationId, err)
+		} else {
+			msg.logSeq = seq
+		}
+	}
+
+	msg.queuedAt = d.clock.Now()
+	d.push(msg, delay)
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *DelayedQueueImp) Run(ctx context.Context) {
+	timer := d.clock.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		due, next := d.popDue(d.clock.Now())
+
+		for _, msg := range due {
+			if err := d.target.Send(msg); err != nil {
+				log.Printf("Erro ao devolver mensagem %s para a fila: %v", msg.CorrelationId, err)
+				d.push(msg, redeliveryDelay)
+				continue
+			}
+
+			d.latency.Record(d.clock.Since(msg.queuedAt))
+		}
+
+		wait := time.Hour
+		if !next.IsZero() {
+			wait = next.Sub(d.clock.Now())
+		}
+
+		timer.Reset(wait)
+
+		select {
+		case <-ctx.Done():
+			fmt.Println("Agendador encerrado")
+			return
+		case <-d.wake:
+		case <-timer.C():
+		}
+	}
+}
+
+func (d *DelayedQueueImp) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return len(d.items)
+}
+
+func (d *DelayedQueueImp) Snapshot() []Message {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	msgs := make([]Message, 0, len(d.items))
+	for _, item := range d.items {
+		msgs = append(msgs, item.msg)
+	}
+	d.items = delayHeap{}
+
+	return msgs
+}
+
+func (d *DelayedQueueImp) push(msg Message, delay time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	heap.Push(&d.items, delayedItem{msg: msg, dueAt: d.clock.Now().Add(delay)})
+}
+
+func (d *DelayedQueueImp) popDue(now time.Time) ([]Message, time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var due []Message
+
+	for len(d.items) > 0 && !d.items[0].dueAt.After(now) {
+		item := heap.Pop(&d.items).(delayedItem)
+		due = append(due, item.msg)
+	}
+
+	if len(d.items) == 0 {
+		return due, time.Time{}
+	}
+
+	return due, d.items[0].dueAt
+}
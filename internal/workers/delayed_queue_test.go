@@ -0,0 +1,43 @@
+package workers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Patrignani/patrignani-rinha-backend-go/internal/testutil"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/clock"
+)
+
+func TestDelayedQueueDeliversAfterDelay(t *testing.T) {
+	fake := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	target := NewQueueWorker(QueueOptions{Buffer: 10})
+	delayed := NewDelayedQueue(target, nil, nil, fake)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go delayed.Run(ctx)
+
+	delayed.SendAfter(Message{CorrelationId: "b"}, 300*time.Millisecond)
+	delayed.SendAfter(Message{CorrelationId: "a"}, 100*time.Millisecond)
+
+	fake.Advance(99 * time.Millisecond)
+	testutil.Stays(t, func() bool { fake.Advance(0); return target.Len() == 0 })
+
+	fake.Advance(time.Millisecond)
+	testutil.Eventually(t, func() bool { fake.Advance(0); return target.Len() == 1 })
+	if delayed.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1", delayed.Len())
+	}
+
+	fake.Advance(199 * time.Millisecond)
+	testutil.Stays(t, func() bool { fake.Advance(0); return target.Len() == 1 })
+
+	fake.Advance(time.Millisecond)
+	testutil.Eventually(t, func() bool { fake.Advance(0); return target.Len() == 2 })
+
+	msgs := target.Snapshot()
+	if msgs[0].CorrelationId != "a" || msgs[1].CorrelationId != "b" {
+		t.Errorf("ordem de entrega = %s, %s, want a, b", msgs[0].CorrelationId, msgs[1].CorrelationId)
+	}
+}
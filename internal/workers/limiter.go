@@ -0,0 +1,88 @@
+package workers
+
+import (
+	"context"
+	"sync"
+)
+
+// Limiter é um semáforo cujo tamanho pode ser alterado enquanto está em uso.
+// Reduzir o limite não interrompe quem já adquiriu; apenas impede novas
+// aquisições até que o número de ativos caia abaixo do novo valor.
+type Limiter struct {
+	limit   int
+	active  int
+	changed chan struct{}
+	mu      sync.Mutex
+}
+
+func NewLimiter(limit int) *Limiter {
+	if limit < 1 {
+		limit = 1
+	}
+
+	return &Limiter{
+		limit:   limit,
+		changed: make(chan struct{}),
+	}
+}
+
+func (l *Limiter) Acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.active < l.limit {
+			l.active++
+			l.mu.Unlock()
+			return nil
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+func (l *Limiter) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.active--
+	l.broadcast()
+}
+
+func (l *Limiter) SetLimit(limit int) {
+	if limit < 1 {
+		limit = 1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limit = limit
+	l.broadcast()
+}
+
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.limit
+}
+
+func (l *Limiter) Available() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.active >= l.limit {
+		return 0
+	}
+	return l.limit - l.active
+}
+
+func (l *Limiter) broadcast() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
@@ -0,0 +1,239 @@
+package workers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+type MessageLog interface {
+	Append(msg Message) (uint64, error)
+	Ack(msg Message) error
+	Replay() []Message
+	Compact() error
+	Close() error
+}
+
+type logRecord struct {
+	Op            string   `json:"op"`
+	Seq           uint64   `json:"seq"`
+	CorrelationId string   `json:"id"`
+	Msg           *Message `json:"msg,omitempty"`
+}
+
+type logEntry struct {
+	seq uint64
+	msg Message
+}
+
+type FileMessageLog struct {
+	path    string
+	sync    bool
+	file    *os.File
+	seq     uint64
+	pending map[string]logEntry
+	stale   int
+	mu      sync.Mutex
+}
+
+func NewFileMessageLog(path string, sync bool) (MessageLog, error) {
+	l := &FileMessageLog{
+		path:    path,
+		sync:    sync,
+		pending: map[string]logEntry{},
+	}
+
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("erro abrir log de mensagens: %w", err)
+	}
+	l.file = file
+
+	return l, nil
+}
+
+func (l *FileMessageLog) Append(msg Message) (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	msg.logSeq = l.seq
+
+	if err := l.write(logRecord{Op: "append", Seq: l.seq, CorrelationId: msg.CorrelationId, Msg: &msg}); err != nil {
+		return 0, err
+	}
+
+	if _, ok := l.pending[msg.CorrelationId]; ok {
+		l.stale++
+	}
+	l.pending[msg.CorrelationId] = logEntry{seq: l.seq, msg: msg}
+
+	return l.seq, nil
+}
+
+func (l *FileMessageLog) Ack(msg Message) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.pending[msg.CorrelationId]
+	if !ok || entry.seq != msg.logSeq {
+		return nil
+	}
+
+	if err := l.write(logRecord{Op: "ack", Seq: msg.logSeq, CorrelationId: msg.CorrelationId}); err != nil {
+		return err
+	}
+
+	delete(l.pending, msg.CorrelationId)
+	l.stale++
+
+	return nil
+}
+
+func (l *FileMessageLog) Replay() []Message {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := make([]logEntry, 0, len(l.pending))
+	for _, entry := range l.pending {
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+
+	msgs := make([]Message, 0, len(entries))
+	for _, entry := range entries {
+		msgs = append(msgs, entry.msg)
+	}
+
+	return msgs
+}
+
+// Compact reescreve o arquivo mantendo apenas as mensagens ainda não confirmadas.
+func (l *FileMessageLog) Compact() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.stale == 0 {
+		return nil
+	}
+
+	tmpPath := l.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("erro criar log temporário: %w", err)
+	}
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range l.pending {
+		msg := entry.msg
+		if err := encoder.Encode(logRecord{Op: "append", Seq: entry.seq, CorrelationId: msg.CorrelationId, Msg: &msg}); err != nil {
+			tmp.Close()
+			return fmt.Errorf("erro compactar log: %w", err)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("erro compactar log: %w", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("erro compactar log: %w", err)
+	}
+	tmp.Close()
+
+	if err := os.Rename(tmpPath, l.path); err != nil {
+		return fmt.Errorf("erro substituir log: %w", err)
+	}
+
+	l.file.Close()
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("erro reabrir log de mensagens: %w", err)
+	}
+
+	l.file = file
+	l.stale = 0
+
+	return nil
+}
+
+func (l *FileMessageLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.file.Close()
+}
+
+func (l *FileMessageLog) write(record logRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("erro serializar registro: %w", err)
+	}
+
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("erro gravar log de mensagens: %w", err)
+	}
+
+	if l.sync {
+		return l.file.Sync()
+	}
+
+	return nil
+}
+
+func (l *FileMessageLog) load() error {
+	file, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("erro abrir log de mensagens: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		var record logRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// uma linha truncada no final indica queda durante a escrita
+			continue
+		}
+
+		if record.Seq > l.seq {
+			l.seq = record.Seq
+		}
+
+		switch record.Op {
+		case "append":
+			if record.Msg == nil {
+				continue
+			}
+			msg := *record.Msg
+			msg.logSeq = record.Seq
+			if _, ok := l.pending[record.CorrelationId]; ok {
+				l.stale++
+			}
+			l.pending[record.CorrelationId] = logEntry{seq: record.Seq, msg: msg}
+		case "ack":
+			if entry, ok := l.pending[record.CorrelationId]; ok && entry.seq == record.Seq {
+				delete(l.pending, record.CorrelationId)
+			}
+			l.stale++
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,84 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+)
+
+// panicGuard impede que um panic dentro de process derrube o binário. Cada
+// panic é contado por mensagem; ao atingir maxPanics a mensagem vai para a
+// quarentena com o stack trace, senão volta para a fila.
+type panicGuard struct {
+	maxPanics  int
+	quarantine DeadLetterStore
+	counts     map[string]int
+	mu         sync.Mutex
+}
+
+func newPanicGuard(maxPanics int, quarantine DeadLetterStore) *panicGuard {
+	if maxPanics < 1 {
+		maxPanics = 1
+	}
+
+	return &panicGuard{
+		maxPanics:  maxPanics,
+		quarantine: quarantine,
+		counts:     map[string]int{},
+	}
+}
+
+// run devolve o erro de process e se a mensagem deve ser reenviada à fila.
+func (g *panicGuard) run(ctx context.Context, msg Message, process func(context.Context, Message) error) (retry bool, err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		stack := string(debug.Stack())
+		err = fmt.Errorf("panic: %v", r)
+		count := g.record(msg.CorrelationId)
+
+		log.Printf("Panic ao processar mensagem %s (%d/%d): %v\n%s", msg.CorrelationId, count, g.maxPanics, r, stack)
+
+		if count < g.maxPanics {
+			retry = true
+			return
+		}
+
+		g.forget(msg.CorrelationId)
+
+		if g.quarantine == nil {
+			log.Printf("Mensagem %s descartada após %d panics", msg.CorrelationId, count)
+			return
+		}
+
+		msg.LastError = fmt.Sprintf("%v\n%s", r, stack)
+		g.quarantine.Add(msg, fmt.Sprintf("poison message: %d panics", count))
+	}()
+
+	err = process(ctx, msg)
+	if err == nil {
+		g.forget(msg.CorrelationId)
+	}
+
+	return false, err
+}
+
+func (g *panicGuard) record(correlationId string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.counts[correlationId]++
+	return g.counts[correlationId]
+}
+
+func (g *panicGuard) forget(correlationId string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.counts, correlationId)
+}
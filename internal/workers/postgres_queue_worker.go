@@ -0,0 +1,282 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/clock"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/metrics"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/storage"
+	"github.com/shopspring/decimal"
+)
+
+type PostgresQueueOptions struct {
+	Name         string
+	PollInterval time.Duration
+	ClaimTimeout time.Duration
+	Quarantine   DeadLetterStore
+	MaxPanics    int
+	Latency      *metrics.Histogram
+	Clock        clock.Clock
+}
+
+type PostgresQueueWorkerImp struct {
+	*consumer
+	pg           storage.PostgresClient
+	name         string
+	pollInterval time.Duration
+	claimTimeout time.Duration
+	notify       chan struct{}
+}
+
+func NewPostgresQueueWorker(pg storage.PostgresClient, opts PostgresQueueOptions) QueueWorker {
+	return &PostgresQueueWorkerImp{
+		consumer:     newConsumer(opts.MaxPanics, opts.Quarantine, opts.Latency, opts.Clock),
+		pg:           pg,
+		name:         opts.Name,
+		pollInterval: opts.PollInterval,
+		claimTimeout: opts.ClaimTimeout,
+		notify:       make(chan struct{}, 1),
+	}
+}
+
+// TrySend não tem limite: o backlog fica no banco, compartilhado entre as
+// instâncias.
+func (q *PostgresQueueWorkerImp) TrySend(msg Message) error {
+	return q.Send(msg)
+}
+
+func (q *PostgresQueueWorkerImp) Send(msg Message) error {
+	return q.sendAfter(msg, 0)
+}
+
+// sendAfter grava a mensagem visível só depois de delay. O horário é
+// calculado pelo banco, o mesmo relógio usado por claim.
+func (q *PostgresQueueWorkerImp) sendAfter(msg Message, delay time.Duration) error {
+	sql := `
+		INSERT INTO queue_messages (queue, correlationId, amount, enqueue_at, reprocessed, last_error, last_processor, last_status_code, queued_at, visible_at, confirmed_by, confirmed_at, breaker_rejected)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, now() + ($10 * interval '1 millisecond'), $11, $12, $13)
+	`
+
+	var confirmedAt *time.Time
+	if !msg.ConfirmedAt.IsZero() {
+		confirmedAt = &msg.ConfirmedAt
+	}
+
+	_, err := q.pg.Exec(context.Background(), sql,
+		q.name,
+		msg.CorrelationId,
+		msg.Amount,
+		msg.EnqueueAt,
+		msg.ReprocessedHowManyTimes,
+		msg.LastError,
+		msg.LastProcessor,
+		msg.LastStatusCode,
+		q.clock.Now().UTC(),
+		delay.Milliseconds(),
+		msg.ConfirmedBy,
+		confirmedAt,
+		msg.BreakerRejected,
+	)
+	if err != nil {
+		return fmt.Errorf("erro enfileirar mensagem %s: %w", msg.CorrelationId, err)
+	}
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (q *PostgresQueueWorkerImp) RetryFallback() {}
+
+func (q *PostgresQueueWorkerImp) CountFallback() int {
+	return 0
+}
+
+// Len conta só as mensagens reservadas por esta instância e ainda em
+// processamento. As demais linhas da tabela continuam persistidas e podem ser
+// consumidas por outra réplica, então não seguram o encerramento desta.
+func (q *PostgresQueueWorkerImp) Len() int {
+	return int(q.counters.inFlight.Load())
+}
+
+// Snapshot não tem o que devolver: as mensagens já estão persistidas no banco.
+func (q *PostgresQueueWorkerImp) Snapshot() []Message {
+	return nil
+}
+
+// Stats conta como profundidade as mensagens ainda não reservadas; as
+// reservadas por qualquer instância entram em InFlight.
+func (q *PostgresQueueWorkerImp) Stats() QueueStats {
+	sql := `
+		SELECT
+			COUNT(*) FILTER (WHERE claimed_at IS NULL),
+			COUNT(*) FILTER (WHERE claimed_at IS NOT NULL),
+			MIN(enqueue_at)
+		FROM queue_messages
+		WHERE queue = $1
+	`
+
+	var depth, claimed int
+	var oldest *time.Time
+
+	stats := QueueStats{
+		Workers:   q.limiter.Limit(),
+		Processed: q.counters.processed.Load(),
+		Failed:    q.counters.failed.Load(),
+	}
+
+	if err := q.pg.QueryRow(context.Background(), sql, q.name).Scan(&depth, &claimed, &oldest); err != nil {
+		log.Printf("[%s] Erro ao consultar estatísticas: %v", q.name, err)
+		return stats
+	}
+
+	stats.Depth = depth
+	stats.InFlight = int64(claimed)
+	if oldest != nil {
+		stats.OldestAgeMs = q.clock.Since(*oldest).Milliseconds()
+	}
+
+	return stats
+}
+
+func (q *PostgresQueueWorkerImp) Consume(ctx context.Context, workers int, process func(context.Context, Message) error) {
+	var wg sync.WaitGroup
+	q.limiter.SetLimit(workers)
+	processCtx := context.WithoutCancel(ctx)
+
+	for {
+		if ctx.Err() != nil {
+			wg.Wait()
+			fmt.Println("Consumo encerrado")
+			return
+		}
+
+		free := max(q.limiter.Available(), 1)
+
+		msgs, err := q.claim(ctx, free)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[%s] Erro ao buscar mensagens: %v", q.name, err)
+		}
+
+		if len(msgs) == 0 {
+			poll := q.clock.NewTimer(q.pollInterval)
+			select {
+			case <-ctx.Done():
+			case <-q.notify:
+			case <-poll.C():
+			}
+			poll.Stop()
+			continue
+		}
+
+		for _, msg := range msgs {
+			if err := q.limiter.Acquire(ctx); err != nil {
+				break
+			}
+
+			q.dispatch(processCtx, &wg, msg, process, q.Send, q.remove)
+		}
+	}
+}
+
+// claim reserva até limit mensagens já visíveis da fila. FOR UPDATE SKIP LOCKED permite que
+// várias instâncias consumam a mesma fila sem disputar as mesmas linhas, e
+// mensagens reservadas por uma instância que morreu voltam a ficar disponíveis
+// depois de claimTimeout.
+func (q *PostgresQueueWorkerImp) claim(ctx context.Context, limit int) ([]Message, error) {
+	sql := `
+		UPDATE queue_messages
+		SET claimed_at = now()
+		WHERE id IN (
+			SELECT id
+			FROM queue_messages
+			WHERE queue = $1
+				AND visible_at <= now()
+				AND (claimed_at IS NULL OR claimed_at < now() - ($2 * interval '1 millisecond'))
+			ORDER BY id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, correlationId::text, amount::text, enqueue_at, reprocessed, last_error, last_processor, last_status_code, queued_at, confirmed_by, confirmed_at, breaker_rejected
+	`
+
+	rows, err := q.pg.Query(ctx, sql, q.name, q.claimTimeout.Milliseconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []Message
+
+	for rows.Next() {
+		var msg Message
+		var amount string
+		var confirmedAt *time.Time
+
+		if err := rows.Scan(&msg.outboxId, &msg.CorrelationId, &amount, &msg.EnqueueAt, &msg.ReprocessedHowManyTimes, &msg.LastError, &msg.LastProcessor, &msg.LastStatusCode, &msg.queuedAt, &msg.ConfirmedBy, &confirmedAt, &msg.BreakerRejected); err != nil {
+			return nil, err
+		}
+
+		if confirmedAt != nil {
+			msg.ConfirmedAt = *confirmedAt
+		}
+
+		msg.Amount, err = decimal.NewFromString(amount)
+		if err != nil {
+			return nil, err
+		}
+
+		msgs = append(msgs, msg)
+	}
+
+	return msgs, rows.Err()
+}
+
+func (q *PostgresQueueWorkerImp) remove(msg Message, _ error) {
+	sql := `DELETE FROM queue_messages WHERE id = $1`
+	if _, err := q.pg.Exec(context.Background(), sql, msg.outboxId); err != nil {
+		log.Printf("[%s] Erro ao remover mensagem %s: %v", q.name, msg.CorrelationId, err)
+	}
+}
+
+// PostgresDelayedQueueImp agenda a mensagem direto na tabela da fila de
+// destino, com visible_at no futuro, então ela sobrevive a uma queda da
+// instância. Se a gravação falhar, a mensagem fica no DelayedQueue em memória,
+// que tenta entregá-la com Send ao vencer o prazo.
+type PostgresDelayedQueueImp struct {
+	target   *PostgresQueueWorkerImp
+	fallback DelayedQueue
+}
+
+func NewPostgresDelayedQueue(target *PostgresQueueWorkerImp, fallback DelayedQueue) DelayedQueue {
+	return &PostgresDelayedQueueImp{
+		target:   target,
+		fallback: fallback,
+	}
+}
+
+func (d *PostgresDelayedQueueImp) SendAfter(msg Message, delay time.Duration) {
+	if err := d.target.sendAfter(msg, delay); err != nil {
+		log.Printf("[%s] Erro ao agendar mensagem %s, mantendo em memória: %v", d.target.name, msg.CorrelationId, err)
+		d.fallback.SendAfter(msg, delay)
+	}
+}
+
+func (d *PostgresDelayedQueueImp) Run(ctx context.Context) {
+	d.fallback.Run(ctx)
+}
+
+func (d *PostgresDelayedQueueImp) Len() int {
+	return d.fallback.Len()
+}
+
+func (d *PostgresDelayedQueueImp) Snapshot() []Message {
+	return d.fallback.Snapshot()
+}
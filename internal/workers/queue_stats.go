@@ -0,0 +1,91 @@
+package workers
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type QueueStats struct {
+	Workers     int   `json:"workers"`
+	Depth       int   `json:"depth"`
+	Fallback    int   `json:"fallback"`
+	InFlight    int64 `json:"inFlight"`
+	Processed   int64 `json:"processed"`
+	Failed      int64 `json:"failed"`
+	OldestAgeMs int64 `json:"oldestAgeMs"`
+}
+
+// queueCounters acompanha o que passa pelos workers de uma fila. Como o
+// conteúdo de um canal não pode ser inspecionado, a idade mais antiga é
+// calculada sobre as mensagens em processamento e a última retirada da fila.
+type queueCounters struct {
+	inFlight  atomic.Int64
+	processed atomic.Int64
+	failed    atomic.Int64
+	running   map[string]time.Time
+	head      time.Time
+	mu        sync.Mutex
+}
+
+func newQueueCounters() *queueCounters {
+	return &queueCounters{
+		running: map[string]time.Time{},
+	}
+}
+
+func (c *queueCounters) start(msg Message) {
+	c.inFlight.Add(1)
+
+	c.mu.Lock()
+	c.running[msg.CorrelationId] = msg.EnqueueAt
+	c.head = msg.EnqueueAt
+	c.mu.Unlock()
+}
+
+func (c *queueCounters) finish(msg Message, err error) {
+	c.inFlight.Add(-1)
+	if err != nil {
+		c.failed.Add(1)
+	} else {
+		c.processed.Add(1)
+	}
+
+	c.mu.Lock()
+	delete(c.running, msg.CorrelationId)
+	c.mu.Unlock()
+}
+
+func (c *queueCounters) stats(depth int, fallback []Message, now time.Time) QueueStats {
+	c.mu.Lock()
+	oldest := c.head
+	for _, enqueueAt := range c.running {
+		oldest = older(oldest, enqueueAt)
+	}
+	c.mu.Unlock()
+
+	for _, msg := range fallback {
+		oldest = older(oldest, msg.EnqueueAt)
+	}
+
+	stats := QueueStats{
+		Depth:     depth,
+		Fallback:  len(fallback),
+		InFlight:  c.inFlight.Load(),
+		Processed: c.processed.Load(),
+		Failed:    c.failed.Load(),
+	}
+
+	if !oldest.IsZero() && depth+len(fallback)+int(stats.InFlight) > 0 {
+		stats.OldestAgeMs = now.Sub(oldest).Milliseconds()
+	}
+
+	return stats
+}
+
+func older(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+	return a
+}
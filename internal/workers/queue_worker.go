@@ -2,49 +2,117 @@ package workers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"sync"
 	"time"
 
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/clock"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/metrics"
 	"github.com/shopspring/decimal"
 )
 
 type Message struct {
-	CorrelationId           string
-	Amount                  decimal.Decimal
-	EnqueueAt               time.Time
-	ReprocessedHowManyTimes int
+	CorrelationId           string          `json:"correlationId"`
+	Amount                  decimal.Decimal `json:"amount"`
+	EnqueueAt               time.Time       `json:"enqueueAt"`
+	ReprocessedHowManyTimes int             `json:"reprocessedHowManyTimes"`
+	LastError               string          `json:"lastError,omitempty"`
+	LastProcessor           string          `json:"lastProcessor,omitempty"`
+	LastStatusCode          int             `json:"lastStatusCode,omitempty"`
+	RetryDelay              time.Duration   `json:"retryDelay,omitempty"`
+	// BreakerRejected indica que a última passagem foi recusada pelo disjuntor
+	// sem chegar ao processador, o que não conta como tentativa.
+	BreakerRejected bool `json:"breakerRejected,omitempty"`
+	// ConfirmedBy e ConfirmedAt marcam um pagamento que o processador já
+	// confirmou, mas que não chegou a ser gravado: falta só o registro.
+	ConfirmedBy string    `json:"confirmedBy,omitempty"`
+	ConfirmedAt time.Time `json:"confirmedAt,omitempty"`
+
+	logSeq   uint64
+	outboxId int64
+	queuedAt time.Time
 }
 
+var ErrQueueFull = errors.New("fila cheia")
+
+// Send nunca recusa por falta de espaço: é usado nas passagens internas, em
+// que a mensagem já foi aceita e não pode ser perdida. TrySend é a porta de
+// entrada e devolve ErrQueueFull quando a fila atingiu MaxPending.
 type QueueWorker interface {
-	Send(msg Message)
+	Send(msg Message) error
+	TrySend(msg Message) error
 	RetryFallback()
 	Consume(ctx context.Context, workers int, process func(context.Context, Message) error)
 	CountFallback() int
+	Len() int
+	Snapshot() []Message
+	Stats() QueueStats
+	Workers() int
+	SetWorkers(workers int)
+}
+
+type QueueOptions struct {
+	Buffer     int
+	MaxPending int
+	Log        MessageLog
+	Quarantine DeadLetterStore
+	MaxPanics  int
+	Latency    *metrics.Histogram
+	Clock      clock.Clock
 }
 
 type QueueWorkerImp struct {
-	channel  chan Message
-	fallback []Message
-	mu       sync.Mutex
+	*consumer
+	channel    chan Message
+	fallback   []Message
+	maxPending int
+	log        MessageLog
+	mu         sync.Mutex
 }
 
-func NewQueueWorker(buffer int) QueueWorker {
+func NewQueueWorker(opts QueueOptions) QueueWorker {
 	return &QueueWorkerImp{
-		channel:  make(chan Message, buffer),
-		fallback: []Message{},
+		consumer:   newConsumer(opts.MaxPanics, opts.Quarantine, opts.Latency, opts.Clock),
+		channel:    make(chan Message, opts.Buffer),
+		fallback:   []Message{},
+		maxPending: opts.MaxPending,
+		log:        opts.Log,
+	}
+}
+
+// TrySend recusa a mensagem com ErrQueueFull quando canal e fallback juntos
+// já atingiram maxPending (zero desativa o limite).
+func (q *QueueWorkerImp) TrySend(msg Message) error {
+	if q.maxPending > 0 && q.Len() >= q.maxPending {
+		return ErrQueueFull
 	}
+
+	return q.Send(msg)
 }
 
-func (q *QueueWorkerImp) Send(msg Message) {
+func (q *QueueWorkerImp) Send(msg Message) error {
+	if q.log != nil {
+		seq, err := q.log.Append(msg)
+		if err != nil {
+			log.Printf("Erro ao gravar mensagem %s no log: %v", msg.CorrelationId, err)
+		} else {
+			msg.logSeq = seq
+		}
+	}
+
+	msg.queuedAt = q.clock.Now()
+
 	select {
 	case q.channel <- msg:
 	default:
 		q.mu.Lock()
 		q.fallback = append(q.fallback, msg)
 		q.mu.Unlock()
-		fmt.Println("Fila cheia, mensagem salva no fallback:", msg.CorrelationId)
 	}
+
+	return nil
 }
 
 func (q *QueueWorkerImp) RetryFallback() {
@@ -56,7 +124,6 @@ func (q *QueueWorkerImp) RetryFallback() {
 	for _, msg := range q.fallback {
 		select {
 		case q.channel <- msg:
-			fmt.Println("Mensagem reprocessada do fallback:", msg.CorrelationId)
 		default:
 			newFallback = append(newFallback, msg)
 		}
@@ -65,38 +132,86 @@ func (q *QueueWorkerImp) RetryFallback() {
 	q.fallback = newFallback
 }
 
+// Consume processa mensagens até ctx ser cancelado e então espera as que já
+// estão em andamento. O canal nunca é fechado, pois outros produtores podem
+// continuar enviando; o que sobrar é recolhido por Snapshot.
 func (q *QueueWorkerImp) Consume(ctx context.Context, workers int, process func(context.Context, Message) error) {
 	var wg sync.WaitGroup
-	sem := make(chan struct{}, workers)
+	q.limiter.SetLimit(workers)
+	processCtx := context.WithoutCancel(ctx)
 
 	for {
 		select {
 		case <-ctx.Done():
 			wg.Wait()
-			close(q.channel)
 			fmt.Println("Consumo encerrado")
 			return
-		case msg, ok := <-q.channel:
-			if !ok {
-				wg.Wait()
-				fmt.Println("Canal fechado e todas mensagens processadas")
-				return
+		case msg := <-q.channel:
+			if err := q.limiter.Acquire(ctx); err != nil {
+				q.requeue(msg)
+				continue
 			}
 
-			sem <- struct{}{}
-			wg.Add(1)
-
-			go func(m Message) {
-				defer wg.Done()
-				defer func() { <-sem }()
-				if err := process(ctx, m); err != nil {
-					fmt.Printf("Erro ao processar mensagem %s: %v\n", m.CorrelationId, err)
-				}
-			}(msg)
+			q.dispatch(processCtx, &wg, msg, process, q.Send, q.ack)
 		}
 	}
 }
 
 func (q *QueueWorkerImp) CountFallback() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
 	return len(q.fallback)
 }
+
+func (q *QueueWorkerImp) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.channel) + len(q.fallback)
+}
+
+// Snapshot esvazia canal e fallback e devolve as mensagens pendentes.
+func (q *QueueWorkerImp) Snapshot() []Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	msgs := append([]Message{}, q.fallback...)
+	q.fallback = []Message{}
+
+	for {
+		select {
+		case msg := <-q.channel:
+			msgs = append(msgs, msg)
+		default:
+			return msgs
+		}
+	}
+}
+
+func (q *QueueWorkerImp) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats := q.counters.stats(len(q.channel), q.fallback, q.clock.Now())
+	stats.Workers = q.limiter.Limit()
+
+	return stats
+}
+
+func (q *QueueWorkerImp) ack(msg Message, err error) {
+	if err != nil || q.log == nil {
+		return
+	}
+
+	if err := q.log.Ack(msg); err != nil {
+		log.Printf("Erro ao confirmar mensagem %s no log: %v", msg.CorrelationId, err)
+	}
+}
+
+func (q *QueueWorkerImp) requeue(msg Message) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.fallback = append(q.fallback, msg)
+}
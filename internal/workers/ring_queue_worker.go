@@ -0,0 +1,269 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+type ringSlot struct {
+	seq atomic.Uint64
+	msg Message
+}
+
+// ringBuffer é uma fila MPMC limitada sem locks (algoritmo de Dmitry Vyukov).
+// Cada slot guarda um número de sequência que indica se está livre para o
+// produtor da volta atual ou pronto para o consumidor, então push e pop só
+// disputam um CAS em head ou tail.
+type ringBuffer struct {
+	_     [64]byte
+	head  atomic.Uint64
+	_     [56]byte
+	tail  atomic.Uint64
+	_     [56]byte
+	mask  uint64
+	slots []ringSlot
+}
+
+func newRingBuffer(capacity int) *ringBuffer {
+	size := uint64(1)
+	for size < uint64(max(capacity, 2)) {
+		size <<= 1
+	}
+
+	r := &ringBuffer{
+		mask:  size - 1,
+		slots: make([]ringSlot, size),
+	}
+
+	for i := range r.slots {
+		r.slots[i].seq.Store(uint64(i))
+	}
+
+	return r
+}
+
+func (r *ringBuffer) push(msg Message) bool {
+	pos := r.tail.Load()
+
+	for {
+		slot := &r.slots[pos&r.mask]
+		diff := int64(slot.seq.Load()) - int64(pos)
+
+		switch {
+		case diff == 0:
+			if r.tail.CompareAndSwap(pos, pos+1) {
+				slot.msg = msg
+				slot.seq.Store(pos + 1)
+				return true
+			}
+			pos = r.tail.Load()
+		case diff < 0:
+			return false
+		default:
+			pos = r.tail.Load()
+		}
+	}
+}
+
+func (r *ringBuffer) pop() (Message, bool) {
+	pos := r.head.Load()
+
+	for {
+		slot := &r.slots[pos&r.mask]
+		diff := int64(slot.seq.Load()) - int64(pos+1)
+
+		switch {
+		case diff == 0:
+			if r.head.CompareAndSwap(pos, pos+1) {
+				msg := slot.msg
+				slot.msg = Message{}
+				slot.seq.Store(pos + r.mask + 1)
+				return msg, true
+			}
+			pos = r.head.Load()
+		case diff < 0:
+			return Message{}, false
+		default:
+			pos = r.head.Load()
+		}
+	}
+}
+
+func (r *ringBuffer) len() int {
+	tail := r.tail.Load()
+	head := r.head.Load()
+	if tail < head {
+		return 0
+	}
+	return int(tail - head)
+}
+
+// RingQueueWorkerImp troca o canal por um ringBuffer e só usa lock no
+// transbordo (spill), que recebe o que não coube no anel.
+type RingQueueWorkerImp struct {
+	*consumer
+	ring       *ringBuffer
+	spill      []Message
+	spillLen   atomic.Int64
+	maxPending int
+	log        MessageLog
+	notify     chan struct{}
+	mu         sync.Mutex
+}
+
+func NewRingQueueWorker(opts QueueOptions) QueueWorker {
+	return &RingQueueWorkerImp{
+		consumer:   newConsumer(opts.MaxPanics, opts.Quarantine, opts.Latency, opts.Clock),
+		ring:       newRingBuffer(opts.Buffer),
+		spill:      []Message{},
+		maxPending: opts.MaxPending,
+		log:        opts.Log,
+		notify:     make(chan struct{}, 1),
+	}
+}
+
+func (q *RingQueueWorkerImp) TrySend(msg Message) error {
+	if q.maxPending > 0 && q.Len() >= q.maxPending {
+		return ErrQueueFull
+	}
+
+	return q.Send(msg)
+}
+
+func (q *RingQueueWorkerImp) Send(msg Message) error {
+	if q.log != nil {
+		seq, err := q.log.Append(msg)
+		if err != nil {
+			log.Printf("Erro ao gravar mensagem %s no log: %v", msg.CorrelationId, err)
+		} else {
+			msg.logSeq = seq
+		}
+	}
+
+	msg.queuedAt = q.clock.Now()
+
+	// Enquanto houver transbordo, as novas vão para o fim dele: o anel só
+	// recebe o transbordo por RetryFallback, que preserva a ordem de chegada.
+	if q.spillLen.Load() > 0 || !q.ring.push(msg) {
+		q.requeue(msg)
+	}
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (q *RingQueueWorkerImp) RetryFallback() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	moved := 0
+	for _, msg := range q.spill {
+		if !q.ring.push(msg) {
+			break
+		}
+		moved++
+	}
+
+	q.spill = append(q.spill[:0], q.spill[moved:]...)
+	q.spillLen.Store(int64(len(q.spill)))
+}
+
+func (q *RingQueueWorkerImp) Consume(ctx context.Context, workers int, process func(context.Context, Message) error) {
+	var wg sync.WaitGroup
+	q.limiter.SetLimit(workers)
+	processCtx := context.WithoutCancel(ctx)
+
+	for {
+		if ctx.Err() != nil {
+			wg.Wait()
+			fmt.Println("Consumo encerrado")
+			return
+		}
+
+		msg, ok := q.ring.pop()
+		if !ok && q.spillLen.Load() > 0 {
+			q.RetryFallback()
+			msg, ok = q.ring.pop()
+		}
+
+		if !ok {
+			select {
+			case <-ctx.Done():
+			case <-q.notify:
+			}
+			continue
+		}
+
+		if err := q.limiter.Acquire(ctx); err != nil {
+			q.requeue(msg)
+			continue
+		}
+
+		q.dispatch(processCtx, &wg, msg, process, q.Send, q.ack)
+	}
+}
+
+func (q *RingQueueWorkerImp) CountFallback() int {
+	return int(q.spillLen.Load())
+}
+
+func (q *RingQueueWorkerImp) Len() int {
+	return q.ring.len() + int(q.spillLen.Load())
+}
+
+// Snapshot devolve primeiro o anel, que guarda as mensagens mais antigas, e
+// depois o transbordo.
+func (q *RingQueueWorkerImp) Snapshot() []Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var msgs []Message
+	for {
+		msg, ok := q.ring.pop()
+		if !ok {
+			break
+		}
+		msgs = append(msgs, msg)
+	}
+
+	msgs = append(msgs, q.spill...)
+	q.spill = []Message{}
+	q.spillLen.Store(0)
+
+	return msgs
+}
+
+func (q *RingQueueWorkerImp) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats := q.counters.stats(q.ring.len(), q.spill, q.clock.Now())
+	stats.Workers = q.limiter.Limit()
+
+	return stats
+}
+
+func (q *RingQueueWorkerImp) ack(msg Message, err error) {
+	if err != nil || q.log == nil {
+		return
+	}
+
+	if err := q.log.Ack(msg); err != nil {
+		log.Printf("Erro ao confirmar mensagem %s no log: %v", msg.CorrelationId, err)
+	}
+}
+
+func (q *RingQueueWorkerImp) requeue(msg Message) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.spill = append(q.spill, msg)
+	q.spillLen.Store(int64(len(q.spill)))
+}
@@ -0,0 +1,162 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Cargas parecidas com as da Rinha: filas de 50 mil posições consumidas por
+// 35 workers, com pico de produtores concorrentes no POST /payments.
+var queueBenchmarks = []struct {
+	name    string
+	buffer  int
+	workers int
+	work    time.Duration
+}{
+	{name: "buffer=50000/workers=35", buffer: 50000, workers: 35},
+	{name: "buffer=1024/workers=35", buffer: 1024, workers: 35},
+	{name: "buffer=50000/workers=35/work=100us", buffer: 50000, workers: 35, work: 100 * time.Microsecond},
+}
+
+func BenchmarkQueueWorker(b *testing.B) {
+	for _, bench := range queueBenchmarks {
+		b.Run(bench.name, func(b *testing.B) {
+			benchmarkQueue(b, NewQueueWorker(QueueOptions{Buffer: bench.buffer}), bench.workers, bench.work)
+		})
+	}
+}
+
+func BenchmarkRingQueueWorker(b *testing.B) {
+	for _, bench := range queueBenchmarks {
+		b.Run(bench.name, func(b *testing.B) {
+			benchmarkQueue(b, NewRingQueueWorker(QueueOptions{Buffer: bench.buffer}), bench.workers, bench.work)
+		})
+	}
+}
+
+// benchmarkQueue mede Send em paralelo até o consumo de todas as mensagens.
+func benchmarkQueue(b *testing.B, queue QueueWorker, workers int, work time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var processed sync.WaitGroup
+	processed.Add(b.N)
+
+	consumed := make(chan struct{})
+	go func() {
+		defer close(consumed)
+		queue.Consume(ctx, workers, func(context.Context, Message) error {
+			if work > 0 {
+				time.Sleep(work)
+			}
+			processed.Done()
+			return nil
+		})
+	}()
+
+	retry, stopRetry := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-retry.Done():
+				return
+			case <-ticker.C:
+				queue.RetryFallback()
+			}
+		}
+	}()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			i++
+			if err := queue.Send(Message{CorrelationId: fmt.Sprint(i)}); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+
+	processed.Wait()
+	b.StopTimer()
+
+	stopRetry()
+	cancel()
+	<-consumed
+}
+
+func TestRingQueueWorkerKeepsOrderWithSpill(t *testing.T) {
+	queue := NewRingQueueWorker(QueueOptions{Buffer: 2})
+
+	for i := range 6 {
+		if err := queue.Send(Message{CorrelationId: fmt.Sprint(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Com transbordo pendente, mesmo um anel com espaço não recebe as novas.
+	queue.(*RingQueueWorkerImp).ring.pop()
+	if err := queue.Send(Message{CorrelationId: "6"}); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := queue.Snapshot()
+	want := []string{"1", "2", "3", "4", "5", "6"}
+	if len(msgs) != len(want) {
+		t.Fatalf("Snapshot() devolveu %d mensagens, want %d", len(msgs), len(want))
+	}
+
+	for i, msg := range msgs {
+		if msg.CorrelationId != want[i] {
+			t.Errorf("msgs[%d] = %s, want %s", i, msg.CorrelationId, want[i])
+		}
+	}
+}
+
+func TestRingQueueWorkerStopsConsumingAfterCancel(t *testing.T) {
+	queue := NewRingQueueWorker(QueueOptions{Buffer: 16})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	release := make(chan struct{})
+	consumed := make(chan struct{})
+	go func() {
+		defer close(consumed)
+		queue.Consume(ctx, 1, func(context.Context, Message) error {
+			<-release
+			return nil
+		})
+	}()
+
+	for i := range 4 {
+		queue.Send(Message{CorrelationId: fmt.Sprint(i)})
+	}
+
+	// Espera o único worker pegar a primeira mensagem e o laço ficar parado em
+	// Acquire com a segunda.
+	for queue.Len() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// O worker só é liberado depois que o laço viu o cancelamento com a vaga
+	// ainda ocupada.
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	select {
+	case <-consumed:
+	case <-time.After(time.Second):
+		t.Fatal("Consume não retornou depois do cancelamento")
+	}
+
+	if got := len(queue.Snapshot()); got != 3 {
+		t.Errorf("restaram %d mensagens, want 3", got)
+	}
+}
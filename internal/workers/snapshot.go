@@ -0,0 +1,48 @@
+package workers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+func SaveSnapshot(path string, msgs []Message) error {
+	return writeJSONFile(path, msgs)
+}
+
+// writeJSONFile grava num arquivo temporário e troca pelo definitivo, para que
+// uma queda no meio da escrita não deixe o arquivo pela metade.
+func writeJSONFile(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("erro serializar %s: %w", path, err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("erro gravar %s: %w", path, err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("erro substituir %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func LoadSnapshot(path string) ([]Message, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ler snapshot: %w", err)
+	}
+
+	var msgs []Message
+	if err := json.Unmarshal(data, &msgs); err != nil {
+		return nil, fmt.Errorf("erro desserializar snapshot: %w", err)
+	}
+
+	return msgs, nil
+}
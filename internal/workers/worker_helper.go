@@ -2,12 +2,49 @@ package workers
 
 import (
 	"context"
+	"hash/fnv"
 	"log"
 	"time"
+
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/clock"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/storage"
 )
 
-func StartWorker(ctx context.Context, name string, interval time.Duration, work func(ctx context.Context) error) {
-	ticker := time.NewTicker(interval)
+type workerOptions struct {
+	clock     clock.Clock
+	singleton storage.PostgresClient
+}
+
+type WorkerOption func(*workerOptions)
+
+// WithClock troca o relógio usado pelo ticker do worker; o padrão é clock.Real.
+func WithClock(c clock.Clock) WorkerOption {
+	return func(o *workerOptions) {
+		o.clock = c
+	}
+}
+
+// WithSingleton faz o worker rodar em apenas uma réplica por vez. A cada tick
+// quem não tem o advisory lock tenta obtê-lo, então outra réplica assume
+// quando a conexão de quem detinha o lock cai.
+func WithSingleton(pg storage.PostgresClient) WorkerOption {
+	return func(o *workerOptions) {
+		o.singleton = pg
+	}
+}
+
+func StartWorker(ctx context.Context, name string, interval time.Duration, work func(ctx context.Context) error, opts ...WorkerOption) {
+	options := workerOptions{clock: clock.Real}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var singleton *singletonLock
+	if options.singleton != nil {
+		singleton = newSingletonLock(options.singleton, name)
+	}
+
+	ticker := options.clock.NewTicker(interval)
 
 	go func() {
 		defer ticker.Stop()
@@ -16,9 +53,16 @@ func StartWorker(ctx context.Context, name string, interval time.Duration, work
 		for {
 			select {
 			case <-ctx.Done():
+				if singleton != nil {
+					singleton.release()
+				}
 				log.Printf("[%s] Encerrando worker", name)
 				return
-			case <-ticker.C:
+			case <-ticker.C():
+				if singleton != nil && !singleton.hold(ctx) {
+					continue
+				}
+
 				err := work(ctx)
 				if err != nil {
 					log.Printf("[%s] Erro ao executar tarefa: %v", name, err)
@@ -27,3 +71,63 @@ func StartWorker(ctx context.Context, name string, interval time.Duration, work
 		}
 	}()
 }
+
+type singletonLock struct {
+	pg   storage.PostgresClient
+	name string
+	key  int64
+	lock storage.AdvisoryLock
+}
+
+// newSingletonLock deriva a chave do advisory lock do nome do worker, então
+// réplicas que registram o mesmo nome disputam o mesmo lock.
+func newSingletonLock(pg storage.PostgresClient, name string) *singletonLock {
+	h := fnv.New64a()
+	h.Write([]byte("worker:" + name))
+
+	return &singletonLock{
+		pg:   pg,
+		name: name,
+		key:  int64(h.Sum64()),
+	}
+}
+
+// hold confirma que o lock continua válido ou tenta obtê-lo. Só é chamado pela
+// goroutine do worker, então não precisa de sincronização.
+func (s *singletonLock) hold(ctx context.Context) bool {
+	if s.lock != nil {
+		if err := s.lock.Ping(ctx); err == nil {
+			return true
+		}
+
+		log.Printf("[%s] Conexão do lock perdida, liberando", s.name)
+		s.release()
+	}
+
+	lock, acquired, err := s.pg.TryAdvisoryLock(ctx, s.key)
+	if err != nil {
+		log.Printf("[%s] Erro ao obter lock: %v", s.name, err)
+		return false
+	}
+
+	if !acquired {
+		return false
+	}
+
+	log.Printf("[%s] Lock obtido, esta réplica executa o worker", s.name)
+	s.lock = lock
+
+	return true
+}
+
+func (s *singletonLock) release() {
+	if s.lock == nil {
+		return
+	}
+
+	if err := s.lock.Release(context.Background()); err != nil {
+		log.Printf("[%s] Erro ao liberar lock: %v", s.name, err)
+	}
+
+	s.lock = nil
+}
@@ -0,0 +1,69 @@
+package workers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Patrignani/patrignani-rinha-backend-go/internal/testutil"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/clock"
+)
+
+func TestStartWorkerFiresOnSchedule(t *testing.T) {
+	fake := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := make(chan time.Time, 10)
+	StartWorker(ctx, "teste", 5*time.Second, func(context.Context) error {
+		calls <- fake.Now()
+		return nil
+	}, WithClock(fake))
+
+	fake.Advance(5*time.Second - time.Millisecond)
+	expectNoCall(t, calls)
+
+	for i := 1; i <= 3; i++ {
+		fake.Advance(time.Millisecond)
+
+		select {
+		case at := <-calls:
+			want := time.Date(2025, 1, 1, 0, 0, 5*i, 0, time.UTC)
+			if !at.Equal(want) {
+				t.Fatalf("chamada %d em %v, want %v", i, at, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("worker não rodou no tick %d", i)
+		}
+
+		fake.Advance(5*time.Second - time.Millisecond)
+		expectNoCall(t, calls)
+	}
+}
+
+func TestStartWorkerStopsOnCancel(t *testing.T) {
+	fake := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	calls := make(chan struct{}, 10)
+	StartWorker(ctx, "teste", time.Second, func(context.Context) error {
+		calls <- struct{}{}
+		return nil
+	}, WithClock(fake))
+
+	cancel()
+	testutil.Eventually(t, func() bool { return fake.Waiters() == 0 })
+
+	fake.Advance(time.Second)
+	expectNoCall(t, calls)
+}
+
+func expectNoCall[T any](t *testing.T, calls chan T) {
+	t.Helper()
+
+	select {
+	case <-calls:
+		t.Fatal("worker rodou antes do intervalo")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
@@ -2,81 +2,304 @@ package main
 
 import (
 	"context"
+	_ "embed"
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/Patrignani/patrignani-rinha-backend-go/internal/repositories"
 	"github.com/Patrignani/patrignani-rinha-backend-go/internal/services"
 	"github.com/Patrignani/patrignani-rinha-backend-go/internal/workers"
 	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/cache"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/circuitbreaker"
 	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/clients"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/clock"
 	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/config"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/metrics"
 	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/models"
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/ratelimit"
 	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/storage"
 	"github.com/gofiber/fiber/v2"
 )
 
+// schema é o mesmo payment.sql do initdb. O initdb só roda com o diretório de
+// dados vazio, então a API o reaplica a cada início para atualizar um banco
+// criado por uma versão anterior.
+//
+//go:embed payment.sql
+var schema string
+
 func main() {
+	if err := config.Load(); err != nil {
+		log.Fatal(err)
+	}
+
+	signalCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// abortCtx só acaba quando o prazo de encerramento se esgota: é o limite
+	// do que ainda está em andamento, como as gravações repetidas de pagamentos
+	// confirmados, que desistem e deixam a mensagem para o snapshot ou o log.
+	abortCtx, abort := context.WithCancel(context.Background())
+	defer abort()
+
 	app := fiber.New()
 
-	atomicCache := cache.NewCostRoutingThresholdCache()
+	clk := clock.Real
+	atomicCache := cache.NewCostRoutingThresholdCache(config.Env.HealthState.ObservationTTL)
 	pg, err := storage.NewPostgresClient(ctx, getPostgresDSN())
 	if err != nil {
 		panic("erro ao iniciar o banco")
 	}
 	defer pg.Close()
 
-	atomicCache.SetHealthDeafultApi(false)
-	atomicCache.SetHealthFallbackApi(false)
+	// Sem argumentos o pgx usa o protocolo simples, que aceita vários comandos
+	// e roda todos numa única transação.
+	if _, err := pg.Exec(ctx, schema); err != nil {
+		log.Fatalf("erro ao atualizar o esquema do banco: %v", err)
+	}
+
+	for _, processor := range config.Env.Processors {
+		atomicCache.SetHealthCheck(processor.Name, cache.HealthHealthy, 0, clk.Now().UTC())
+	}
+
+	if config.Env.HealthState.Mode == "distributed" {
+		distributedCache := cache.NewDistributedCache(atomicCache, pg)
+		if err := distributedCache.Sync(ctx); err != nil {
+			log.Printf("erro ao sincronizar saúde dos processadores: %v", err)
+		}
+
+		workers.StartWorker(ctx, "healthSync", config.Env.HealthState.SyncInterval, distributedCache.Sync)
+		atomicCache = distributedCache
+	}
 
 	httpClient := clients.NewHttpRequest()
 
 	paymentRepo := repositories.NewPaymentRepository(pg)
+	if config.Env.InsertBatch.Size > 0 {
+		paymentRepo = repositories.NewBatchPaymentRepository(abortCtx, paymentRepo, repositories.BatchOptions{
+			Size:     config.Env.InsertBatch.Size,
+			Interval: config.Env.InsertBatch.Interval,
+			Retries:  config.Env.InsertBatch.Retries,
+		})
+	}
+
+	deadLetters := workers.NewDeadLetterStore(clk)
+	if config.Env.DeadLetter.Path != "" {
+		deadLetters, err = workers.NewFileDeadLetterStore(config.Env.DeadLetter.Path, clk)
+		if err != nil {
+			log.Fatalf("erro ao abrir dead letters: %v", err)
+		}
+	}
 
-	screening := workers.NewQueueWorker(config.Env.ScreeningQueue.Buffer)
-	highPriority := workers.NewQueueWorker(config.Env.HighPriorityQueue.Buffer)
-	lowPriority := workers.NewQueueWorker(config.Env.LowPriorityQueue.Buffer)
-	waitingRoom := workers.NewQueueWorker(config.Env.WaitingRoomQueue.Buffer)
+	var messageLog workers.MessageLog
+	if config.Env.MessageLog.Path != "" && config.Env.QueueBackend.Kind != "postgres" {
+		messageLog, err = workers.NewFileMessageLog(config.Env.MessageLog.Path, config.Env.MessageLog.Sync)
+		if err != nil {
+			log.Fatalf("erro ao abrir log de mensagens: %v", err)
+		}
+		defer messageLog.Close()
+	}
 
-	screeningService := services.NewScreeningService(atomicCache, highPriority, lowPriority, waitingRoom)
-	checkHealt := services.NewCheckHealthPaymentService(httpClient, atomicCache)
-	waitServer := services.NewWaitingRoomServer(screening)
-	paymentServer := services.NewPaymentService(httpClient, waitingRoom, atomicCache, paymentRepo)
+	latency := metrics.NewRegistry()
 
-	if config.Env.EnableCheckHealthCheck {
-		workers.StartWorker(ctx, "healthCheckPayment", 5*time.Second+300*time.Millisecond, checkHealt.SetStatusPayment)
+	screening := newQueueWorker(pg, messageLog, deadLetters, latency, clk, "screening", config.Env.ScreeningQueue.Buffer, config.Env.ScreeningQueue.MaxPending)
+	waitingRoom := newQueueWorker(pg, messageLog, deadLetters, latency, clk, "waitingRoom", config.Env.WaitingRoomQueue.Buffer, config.Env.WaitingRoomQueue.MaxPending)
+	urgent := newQueueWorker(pg, messageLog, deadLetters, latency, clk, "urgent", config.Env.UrgentQueue.Buffer, config.Env.UrgentQueue.MaxPending)
+
+	queues := map[string]workers.QueueWorker{
+		"screening":   screening,
+		"waitingRoom": waitingRoom,
+		"urgent":      urgent,
 	}
 
-	workers.StartWorker(ctx, "retryFallback", 100*time.Millisecond, func(ctx context.Context) error {
+	processorQueues := make([]services.ProcessorQueue, 0, len(config.Env.Processors))
+	for _, processor := range config.Env.Processors {
+		queue := newQueueWorker(pg, messageLog, deadLetters, latency, clk, processor.Queue, processor.Buffer, processor.MaxPending)
+		queues[processor.Queue] = queue
+		processorQueues = append(processorQueues, services.ProcessorQueue{Processor: processor, Worker: queue})
+	}
+
+	retryPolicy := newRetryPolicy()
+	healthPolicy := newHealthPolicy()
+	routingPolicy, err := services.NewRoutingPolicy(config.Env.Routing.Policy, services.RoutingOptions{
+		LatencyMargin: config.Env.Routing.LatencyMargin,
+		LatencyCost:   config.Env.Routing.LatencyCost,
+		LatencyBudget: config.Env.Routing.LatencyBudget,
+	})
+	if err != nil {
+		log.Fatalf("erro ao configurar roteamento: %v", err)
+	}
 
-		if screening.CountFallback() > 0 {
-			screening.RetryFallback()
+	breakers := map[string]circuitbreaker.CircuitBreaker{}
+	for _, processor := range config.Env.Processors {
+		breakers[processor.Name] = newCircuitBreaker(clk)
+	}
+
+	screeningService := services.NewScreeningService(atomicCache, processorQueues, waitingRoom, urgent, deadLetters, retryPolicy, services.DeadlinePolicy{
+		MaxAge: config.Env.Deadline.MaxAge,
+		Action: services.DeadlineAction(config.Env.Deadline.Action),
+	}, healthPolicy, breakers, routingPolicy, clk)
+	checkHealt := services.NewCheckHealthPaymentService(httpClient, atomicCache, clk)
+	rescreening := newRescreening(screening, messageLog, latency, clk)
+	waitServer := services.NewWaitingRoomServer(rescreening, deadLetters, services.DeadLetterPolicy{
+		MaxAttempts: config.Env.DeadLetter.MaxAttempts,
+		MaxAge:      config.Env.DeadLetter.MaxAge,
+	}, retryPolicy, clk)
+
+	var adaptiveOpts workers.AdaptiveOptions
+	if config.Env.AdaptiveWorkers.Enabled {
+		adaptiveOpts = workers.AdaptiveOptions{
+			Min:            config.Env.AdaptiveWorkers.Min,
+			Max:            config.Env.AdaptiveWorkers.Max,
+			LatencyTarget:  config.Env.AdaptiveWorkers.LatencyTarget,
+			ErrorThreshold: config.Env.AdaptiveWorkers.ErrorThreshold,
 		}
+	}
 
-		if highPriority.CountFallback() > 0 {
-			highPriority.RetryFallback()
+	limiters := map[string]ratelimit.RateLimiter{}
+	processorOptions := make([]services.ProcessorOptions, 0, len(processorQueues))
+	for _, processor := range processorQueues {
+		limiter := ratelimit.NewTokenBucket(processor.RateLimit, processor.RateBurst)
+		limiters[processor.Name] = limiter
+
+		var observer workers.LatencyObserver
+		if config.Env.AdaptiveWorkers.Enabled {
+			controller := workers.NewAdaptiveController(processor.Queue, processor.Worker, adaptiveOpts)
+			observer = controller
+
+			workers.StartWorker(ctx, "adaptive-"+processor.Name, config.Env.AdaptiveWorkers.Interval, controller.Adjust)
 		}
 
-		if lowPriority.CountFallback() > 0 {
-			lowPriority.RetryFallback()
+		processorOptions = append(processorOptions, services.ProcessorOptions{
+			Name:     processor.Name,
+			Url:      processor.Url,
+			Observer: observer,
+			Limiter:  limiter,
+			Latency:  latency.Histogram("processor." + processor.Name),
+			Breaker:  breakers[processor.Name],
+		})
+	}
+
+	paymentServer := services.NewPaymentService(httpClient, waitingRoom, atomicCache, paymentRepo,
+		processorOptions,
+		latency.Histogram("payment.endToEnd"),
+		clk,
+		healthPolicy,
+	)
+
+	if config.Env.EnableCheckHealthCheck {
+		var healthOpts []workers.WorkerOption
+		if config.Env.HealthCheckSingleton {
+			healthOpts = append(healthOpts, workers.WithSingleton(pg))
 		}
 
-		if waitingRoom.CountFallback() > 0 {
-			waitingRoom.RetryFallback()
+		workers.StartWorker(ctx, "healthCheckPayment", 5*time.Second+300*time.Millisecond, checkHealt.SetStatusPayment, healthOpts...)
+	}
+
+	workers.StartWorker(ctx, "retryFallback", 100*time.Millisecond, func(ctx context.Context) error {
+		for _, queue := range queues {
+			if queue.CountFallback() > 0 {
+				queue.RetryFallback()
+			}
 		}
 
 		return nil
 	})
 
-	go screening.Consume(ctx, config.Env.ScreeningQueue.Workers, screeningService.Redirect)
-	go waitingRoom.Consume(ctx, config.Env.WaitingRoomQueue.Workers, waitServer.Delay)
-	go highPriority.Consume(ctx, config.Env.HighPriorityQueue.Workers, paymentServer.ExecuteFallback)
-	go lowPriority.Consume(ctx, config.Env.LowPriorityQueue.Workers, paymentServer.ExecuteDefault)
+	// Consume não cancela o contexto das mensagens em andamento quando ctx
+	// acaba; o de cada mensagem passa a acabar também com abortCtx.
+	var consumers sync.WaitGroup
+	consume := func(queue workers.QueueWorker, workersCount int, process func(context.Context, workers.Message) error) {
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			queue.Consume(ctx, workersCount, func(msgCtx context.Context, msg workers.Message) error {
+				msgCtx, cancelMsg := context.WithCancel(msgCtx)
+				defer cancelMsg()
+				defer context.AfterFunc(abortCtx, cancelMsg)()
+
+				return process(msgCtx, msg)
+			})
+		}()
+	}
+
+	var scheduler sync.WaitGroup
+	scheduler.Add(1)
+	go func() {
+		defer scheduler.Done()
+		rescreening.Run(ctx)
+	}()
+
+	consume(screening, config.Env.ScreeningQueue.Workers, screeningService.Redirect)
+	consume(waitingRoom, config.Env.WaitingRoomQueue.Workers, waitServer.Delay)
+	for _, processor := range processorQueues {
+		name := processor.Name
+		consume(processor.Worker, processor.Workers, func(ctx context.Context, msg workers.Message) error {
+			return paymentServer.Execute(ctx, name, msg)
+		})
+	}
+	consume(urgent, config.Env.UrgentQueue.Workers, paymentServer.ExecuteUrgent)
+
+	// Log e snapshot são recarregados com os consumidores já rodando, para que
+	// a fila de triagem esvazie enquanto recebe as mensagens.
+	if messageLog != nil {
+		pending := messageLog.Replay()
+		if len(pending) > 0 {
+			log.Printf("Reprocessando %d mensagens pendentes do log", len(pending))
+		}
+
+		// Uma mensagem que não voltar para a fila continua pendente no log,
+		// sobrevive à compactação e é reprocessada no próximo início.
+		for _, msg := range pending {
+			if err := screening.Send(msg); err != nil {
+				log.Printf("Erro ao reprocessar mensagem %s: %v", msg.CorrelationId, err)
+			}
+		}
+
+		workers.StartWorker(ctx, "compactMessageLog", config.Env.MessageLog.CompactInterval, func(ctx context.Context) error {
+			return messageLog.Compact()
+		})
+	}
+
+	if config.Env.Shutdown.SnapshotPath != "" {
+		leftovers, err := workers.LoadSnapshot(config.Env.Shutdown.SnapshotPath)
+		if err != nil {
+			log.Printf("erro ao carregar snapshot: %v", err)
+		}
+
+		if len(leftovers) > 0 {
+			log.Printf("Reprocessando %d mensagens do snapshot", len(leftovers))
+		}
+
+		var failed []workers.Message
+		for _, msg := range leftovers {
+			if err := screening.Send(msg); err != nil {
+				log.Printf("Erro ao reprocessar mensagem %s: %v", msg.CorrelationId, err)
+				failed = append(failed, msg)
+			}
+		}
+
+		// Com erro na leitura o arquivo fica como está; senão ele é removido ou
+		// reescrito só com as mensagens que não voltaram para a fila.
+		if err == nil {
+			if len(failed) == 0 {
+				os.Remove(config.Env.Shutdown.SnapshotPath)
+			} else if err := workers.SaveSnapshot(config.Env.Shutdown.SnapshotPath, failed); err != nil {
+				log.Printf("erro ao regravar snapshot com %d mensagens: %v", len(failed), err)
+			}
+		}
+	}
 
 	app.Get("/health", func(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
@@ -96,15 +319,29 @@ func main() {
 			})
 		}
 
-		screening.Send(workers.Message{
+		err := screening.TrySend(workers.Message{
 			CorrelationId: payload.CorrelationId,
 			Amount:        payload.Amount,
-			EnqueueAt:     time.Now().UTC(),
+			EnqueueAt:     clk.Now().UTC(),
 		})
 
+		if errors.Is(err, workers.ErrQueueFull) {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(config.Env.QueueFullRetryAfter))
+			return c.SendStatus(config.Env.QueueFullStatus)
+		}
+
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Unable to enqueue payment",
+				"error":   err.Error(),
+			})
+		}
+
 		return c.SendStatus(fiber.StatusOK)
 	})
 
+	summaryAliases := config.Env.Processors.LegacyAliases()
 	app.Get("/payments-summary", func(c *fiber.Ctx) error {
 		fromStr := c.Query("from")
 		toStr := c.Query("to")
@@ -131,9 +368,29 @@ func main() {
 			return fiber.NewError(fiber.StatusBadRequest, "error get summary")
 		}
 
+		for _, processor := range config.Env.Processors {
+			summary[processor.Name] = summary[processor.Name].WithFee(processor.Fee)
+		}
+
+		for alias, processor := range summaryAliases {
+			summary[alias] = summary[processor]
+		}
+
 		return c.Status(fiber.StatusOK).JSON(summary)
 	})
 
+	admin := app.Group("/admin")
+	registerQueueRoutes(admin, queues, rescreening)
+	registerDeadLetterRoutes(admin, deadLetters, screening, clk)
+	registerRateLimitRoutes(admin, limiters)
+	registerLatencyRoutes(admin, latency)
+	processorNames := make([]string, 0, len(config.Env.Processors))
+	for _, processor := range config.Env.Processors {
+		processorNames = append(processorNames, processor.Name)
+	}
+	registerHealthRoutes(admin, atomicCache, processorNames)
+	registerCircuitBreakerRoutes(admin, breakers)
+
 	app.Delete("/purge", func(c *fiber.Ctx) error {
 		ctx := context.Background()
 		err := paymentRepo.PurgeAll(ctx)
@@ -160,11 +417,206 @@ func main() {
 ╚════════════════════════════════════════════════════╝
 `, ln.Addr().String())
 
-	if err := app.Listener(ln); err != nil {
-		log.Fatalf("erro ao iniciar servidor: %v", err)
+	go func() {
+		if err := app.Listener(ln); err != nil {
+			log.Fatalf("erro ao iniciar servidor: %v", err)
+		}
+	}()
+
+	<-signalCtx.Done()
+	log.Printf("Sinal de encerramento recebido, finalizando...")
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), config.Env.Shutdown.Timeout)
+	defer cancelShutdown()
+
+	if err := app.ShutdownWithContext(shutdownCtx); err != nil {
+		log.Printf("erro ao encerrar servidor: %v", err)
+	}
+
+	draining := []workers.QueueWorker{screening, urgent}
+	for _, processor := range processorQueues {
+		draining = append(draining, processor.Worker)
+	}
+	drainQueues(shutdownCtx, clk, draining...)
+
+	// O agendador para antes dos snapshots, para não devolver mensagens à
+	// triagem depois que ela já foi recolhida. Os consumidores têm até o fim
+	// do prazo; depois disso abort faz o que está em andamento desistir, e o
+	// snapshot sai mesmo que algum não termine em shutdownGrace.
+	cancel()
+	scheduler.Wait()
+
+	if !waitGroup(shutdownCtx, &consumers) {
+		abort()
+
+		graceCtx, cancelGrace := context.WithTimeout(context.Background(), shutdownGrace)
+		defer cancelGrace()
+
+		if !waitGroup(graceCtx, &consumers) {
+			log.Printf("Consumidores ainda em andamento após o prazo de encerramento")
+		}
+	}
+
+	var leftovers []workers.Message
+	for _, queue := range append(draining, waitingRoom) {
+		leftovers = append(leftovers, queue.Snapshot()...)
+	}
+	leftovers = append(leftovers, rescreening.Snapshot()...)
+
+	// Sem DEAD_LETTER_PATH as dead letters só existem em memória; no snapshot
+	// elas voltam à triagem no próximo início em vez de se perderem.
+	if config.Env.DeadLetter.Path == "" {
+		for _, letter := range deadLetters.List() {
+			leftovers = append(leftovers, letter.Message)
+		}
+	}
+
+	if len(leftovers) > 0 {
+		if config.Env.Shutdown.SnapshotPath == "" {
+			log.Printf("%d mensagens descartadas no encerramento (SNAPSHOT_PATH não configurado)", len(leftovers))
+		} else if err := workers.SaveSnapshot(config.Env.Shutdown.SnapshotPath, leftovers); err != nil {
+			log.Printf("erro ao salvar snapshot: %v", err)
+		} else {
+			log.Printf("%d mensagens salvas em snapshot", len(leftovers))
+		}
+	}
+
+	log.Printf("Servidor encerrado")
+}
+
+// shutdownGrace é quanto o encerramento ainda espera os consumidores depois
+// de abort, antes de salvar o snapshot sem eles.
+const shutdownGrace = time.Second
+
+// waitGroup espera wg terminar; devolve false se ctx acabar antes.
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// drainQueues espera todas as filas ficarem vazias ao mesmo tempo, ou o prazo
+// de ctx acabar.
+func drainQueues(ctx context.Context, clk clock.Clock, queues ...workers.QueueWorker) {
+	ticker := clk.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		pending := 0
+		for _, queue := range queues {
+			pending += queue.Len()
+		}
+
+		if pending == 0 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("Prazo de encerramento esgotado com %d mensagens nas filas", pending)
+			return
+		case <-ticker.C():
+		}
 	}
 }
 
 func getPostgresDSN() string {
 	return fmt.Sprintf("postgresql://%s:%s@%s:%s/%s", config.Env.Postgres.User, config.Env.Postgres.Pass, config.Env.Postgres.Host, config.Env.Postgres.PORT, config.Env.Postgres.Name)
 }
+
+func newQueueWorker(pg storage.PostgresClient, messageLog workers.MessageLog, quarantine workers.DeadLetterStore, latency metrics.Registry, clk clock.Clock, name string, buffer, maxPending int) workers.QueueWorker {
+	if config.Env.QueueBackend.Kind == "postgres" {
+		return workers.NewPostgresQueueWorker(pg, workers.PostgresQueueOptions{
+			Name:         name,
+			PollInterval: config.Env.QueueBackend.PollInterval,
+			ClaimTimeout: config.Env.QueueBackend.ClaimTimeout,
+			Quarantine:   quarantine,
+			MaxPanics:    config.Env.PoisonMaxPanics,
+			Latency:      latency.Histogram("queue." + name),
+			Clock:        clk,
+		})
+	}
+
+	opts := workers.QueueOptions{
+		Buffer:     buffer,
+		MaxPending: maxPending,
+		Log:        messageLog,
+		Quarantine: quarantine,
+		MaxPanics:  config.Env.PoisonMaxPanics,
+		Latency:    latency.Histogram("queue." + name),
+		Clock:      clk,
+	}
+
+	if config.Env.QueueBackend.Kind == "ring" {
+		return workers.NewRingQueueWorker(opts)
+	}
+
+	return workers.NewQueueWorker(opts)
+}
+
+// newRescreening agenda a volta das mensagens da sala de espera para a
+// triagem. Com o backend postgres o agendamento fica na própria tabela, já
+// que a linha da sala de espera é removida assim que Delay retorna.
+func newRescreening(screening workers.QueueWorker, messageLog workers.MessageLog, latency metrics.Registry, clk clock.Clock) workers.DelayedQueue {
+	delayed := workers.NewDelayedQueue(screening, messageLog, latency.Histogram("delay.rescreening"), clk)
+
+	if queue, ok := screening.(*workers.PostgresQueueWorkerImp); ok {
+		return workers.NewPostgresDelayedQueue(queue, delayed)
+	}
+
+	return delayed
+}
+
+func newRetryPolicy() services.RetryPolicy {
+	baseDelay := config.Env.Retry.BaseDelay
+	if baseDelay == 0 {
+		baseDelay = config.Env.WaitingRoomSleepTime
+	}
+
+	return services.RetryPolicy{
+		BaseDelay:           baseDelay,
+		Multiplier:          config.Env.Retry.Multiplier,
+		MaxDelay:            config.Env.Retry.MaxDelay,
+		Jitter:              services.JitterMode(config.Env.Retry.Jitter),
+		BaseChance:          config.Env.Retry.BaseChance,
+		ChanceIncrement:     config.Env.Retry.ChanceIncrement,
+		MaxChance:           config.Env.Retry.MaxChance,
+		FallbackProbeChance: config.Env.Retry.FallbackProbeChance,
+	}
+}
+
+// newHealthPolicy só considera a idade da leitura com o health check ligado:
+// no modo passivo a saúde muda apenas quando há tráfego, e um período ocioso
+// não deve tornar os processadores desconhecidos.
+func newHealthPolicy() services.HealthPolicy {
+	policy := services.HealthPolicy{
+		Unknown:        services.UnknownHealthAction(config.Env.HealthState.Unknown),
+		ObservationTTL: config.Env.HealthState.ObservationTTL,
+	}
+
+	if config.Env.EnableCheckHealthCheck {
+		policy.StaleAfter = config.Env.HealthState.StaleAfter
+	}
+
+	return policy
+}
+
+func newCircuitBreaker(clk clock.Clock) circuitbreaker.CircuitBreaker {
+	return circuitbreaker.New(circuitbreaker.Options{
+		WindowSize:       config.Env.CircuitBreaker.WindowSize,
+		MinRequests:      config.Env.CircuitBreaker.MinRequests,
+		FailureThreshold: config.Env.CircuitBreaker.FailureThreshold,
+		OpenTimeout:      config.Env.CircuitBreaker.OpenTimeout,
+		HalfOpenProbes:   config.Env.CircuitBreaker.HalfOpenProbes,
+		Clock:            clk,
+	})
+}
@@ -1,8 +1,66 @@
-CREATE UNLOGGED TABLE entry_history (
+-- Roda no initdb e também a cada início da API (ver schema em main.go), então
+-- tudo aqui precisa ser idempotente. O advisory lock serializa as réplicas que
+-- sobem juntas.
+SELECT pg_advisory_xact_lock(4242);
+
+CREATE UNLOGGED TABLE IF NOT EXISTS entry_history (
 	correlationId UUID PRIMARY KEY,
 	amount DECIMAL NOT NULL,
-	fallback BOOLEAN NOT NULL DEFAULT TRUE,
+	processor TEXT NOT NULL,
 	created_at TIMESTAMP NOT NULL
 );
 
-CREATE INDEX _created_at_ ON entry_history (created_at);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS _created_at_ ON entry_history (created_at);
+
+-- Bancos criados antes de processor guardavam só a coluna fallback. Ela fica
+-- na tabela, ainda com o default antigo, para não quebrar uma réplica que
+-- ainda não foi atualizada.
+ALTER TABLE entry_history ADD COLUMN IF NOT EXISTS processor TEXT;
+
+DO $$
+BEGIN
+	IF EXISTS (
+		SELECT 1 FROM information_schema.columns
+		WHERE table_name = 'entry_history' AND column_name = 'fallback'
+	) THEN
+		UPDATE entry_history
+		SET processor = CASE WHEN fallback THEN 'fallback' ELSE 'default' END
+		WHERE processor IS NULL;
+	END IF;
+END $$;
+
+ALTER TABLE entry_history ALTER COLUMN processor SET NOT NULL;
+
+CREATE UNLOGGED TABLE IF NOT EXISTS queue_messages (
+	id BIGSERIAL PRIMARY KEY,
+	queue TEXT NOT NULL,
+	correlationId UUID NOT NULL,
+	amount DECIMAL NOT NULL,
+	enqueue_at TIMESTAMP NOT NULL,
+	reprocessed INT NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	last_processor TEXT NOT NULL DEFAULT '',
+	last_status_code INT NOT NULL DEFAULT 0,
+	queued_at TIMESTAMP NOT NULL DEFAULT now(),
+	visible_at TIMESTAMP NOT NULL DEFAULT now(),
+	confirmed_by TEXT NOT NULL DEFAULT '',
+	confirmed_at TIMESTAMP,
+	breaker_rejected BOOLEAN NOT NULL DEFAULT FALSE,
+	claimed_at TIMESTAMP
+);
+
+ALTER TABLE queue_messages ADD COLUMN IF NOT EXISTS visible_at TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE queue_messages ADD COLUMN IF NOT EXISTS confirmed_by TEXT NOT NULL DEFAULT '';
+ALTER TABLE queue_messages ADD COLUMN IF NOT EXISTS confirmed_at TIMESTAMP;
+ALTER TABLE queue_messages ADD COLUMN IF NOT EXISTS breaker_rejected BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS _queue_messages_queue_ ON queue_messages (queue, id);
+
+CREATE UNLOGGED TABLE IF NOT EXISTS processor_health (
+	processor TEXT PRIMARY KEY,
+	state TEXT NOT NULL DEFAULT 'unknown',
+	failing BOOLEAN NOT NULL DEFAULT FALSE,
+	min_response_time INT NOT NULL DEFAULT 0,
+	checked_at TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL
+);
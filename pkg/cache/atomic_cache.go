@@ -1,41 +1,158 @@
 package cache
 
 import (
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/shopspring/decimal"
 )
 
 var zero = decimal.Zero
 
+type HealthState string
+
+const (
+	HealthHealthy HealthState = "healthy"
+	// HealthDegraded indica processador de pé, mas com MinResponseTime acima
+	// de LIMIT_TIME_HEALTH.
+	HealthDegraded HealthState = "degraded"
+	HealthFailing  HealthState = "failing"
+	// HealthUnknown indica que a última verificação falhou ou que a leitura
+	// ficou velha demais para ser confiável.
+	HealthUnknown HealthState = "unknown"
+)
+
+// ProcessorHealth é o retrato de um processador. State, MinResponseTime e
+// CheckedAt vêm do health check; EwmaMs, os percentis e ErrorRate do tráfego
+// observado pela própria instância, e ObservedAt é o horário da última dessas
+// observações. Failing guarda a última leitura conclusiva e não muda quando
+// State passa a unknown, e CheckedAt é o horário dessa leitura.
+type ProcessorHealth struct {
+	State           HealthState `json:"state"`
+	Failing         bool        `json:"failing"`
+	MinResponseTime int         `json:"minResponseTime"`
+	CheckedAt       time.Time   `json:"checkedAt"`
+	EwmaMs          float64     `json:"ewmaMs"`
+	P50Ms           float64     `json:"p50Ms"`
+	P95Ms           float64     `json:"p95Ms"`
+	P99Ms           float64     `json:"p99Ms"`
+	ErrorRate       float64     `json:"errorRate"`
+	ObservedAt      time.Time   `json:"observedAt"`
+}
+
 type AtomicCache interface {
-	SetHealthDeafultApi(defaultAPIOn bool)
-	GetHealthDeafultApi() bool
-	SetHealthFallbackApi(fallbackAPIOn bool)
-	GetHealthFallbackApi() (fallbackAPIOn bool)
+	SetHealthCheck(processor string, state HealthState, minResponseTime int, checkedAt time.Time)
+	Observe(processor string, latency time.Duration, err error, observedAt time.Time)
+	GetProcessorHealth(processor string) ProcessorHealth
 }
 
+// AtomicCacheImp publica cada ProcessorHealth num atomic.Pointer, então a
+// leitura no caminho quente não pega lock; as escritas de um mesmo
+// processador são serializadas pelo mutex do seu processorState.
+// Observações separadas por mais de observationTTL recomeçam a janela: sem
+// tráfego, as medidas antigas não dizem mais nada sobre o processador (zero
+// desativa).
 type AtomicCacheImp struct {
-	defaultAPIOn  atomic.Bool
-	fallbackAPIOn atomic.Bool
+	processors     map[string]*processorState
+	observationTTL time.Duration
+	mu             sync.RWMutex
+}
+
+func NewCostRoutingThresholdCache(observationTTL time.Duration) AtomicCache {
+	return &AtomicCacheImp{
+		processors:     map[string]*processorState{},
+		observationTTL: observationTTL,
+	}
+}
+
+// SetHealthCheck com HealthUnknown só troca State, mantendo a última leitura
+// conclusiva em Failing, MinResponseTime e CheckedAt.
+func (c *AtomicCacheImp) SetHealthCheck(processor string, state HealthState, minResponseTime int, checkedAt time.Time) {
+	c.state(processor).update(func(h *ProcessorHealth) {
+		h.State = state
+		if state == HealthUnknown {
+			return
+		}
+
+		h.Failing = state != HealthHealthy
+		h.MinResponseTime = minResponseTime
+		h.CheckedAt = checkedAt
+	})
+}
+
+func (c *AtomicCacheImp) Observe(processor string, latency time.Duration, err error, observedAt time.Time) {
+	c.state(processor).observe(latency, err, observedAt, c.observationTTL)
 }
 
-func NewCostRoutingThresholdCache() AtomicCache {
-	return &AtomicCacheImp{}
+func (c *AtomicCacheImp) GetProcessorHealth(processor string) ProcessorHealth {
+	return *c.state(processor).snapshot.Load()
 }
 
-func (c *AtomicCacheImp) SetHealthDeafultApi(defaultAPIOn bool) {
-	c.defaultAPIOn.Store(defaultAPIOn)
+func (c *AtomicCacheImp) state(processor string) *processorState {
+	c.mu.RLock()
+	s, ok := c.processors[processor]
+	c.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s, ok := c.processors[processor]; ok {
+		return s
+	}
+
+	s = newProcessorState()
+	c.processors[processor] = s
+
+	return s
+}
+
+type processorState struct {
+	snapshot atomic.Pointer[ProcessorHealth]
+	window   *latencyWindow
+	mu       sync.Mutex
 }
 
-func (c *AtomicCacheImp) GetHealthDeafultApi() bool {
-	return c.defaultAPIOn.Load()
+func newProcessorState() *processorState {
+	s := &processorState{window: newLatencyWindow()}
+	s.snapshot.Store(&ProcessorHealth{State: HealthUnknown})
+
+	return s
 }
 
-func (c *AtomicCacheImp) SetHealthFallbackApi(fallbackAPIOn bool) {
-	c.fallbackAPIOn.Store(fallbackAPIOn)
+func (s *processorState) update(change func(*ProcessorHealth)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := *s.snapshot.Load()
+	change(&next)
+	s.snapshot.Store(&next)
 }
 
-func (c *AtomicCacheImp) GetHealthFallbackApi() (fallbackAPIOn bool) {
-	return c.fallbackAPIOn.Load()
+// observe atualiza a EWMA a cada chamada, mas só recalcula percentis e taxa
+// de erro a cada windowRefresh observações, para não ordenar a janela por
+// pagamento. Depois de um intervalo maior que ttl sem chamadas, a EWMA e a
+// janela recomeçam da observação atual.
+func (s *processorState) observe(latency time.Duration, err error, observedAt time.Time, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := *s.snapshot.Load()
+	if ttl > 0 && !next.ObservedAt.IsZero() && observedAt.Sub(next.ObservedAt) > ttl {
+		s.window = newLatencyWindow()
+		next.EwmaMs, next.P50Ms, next.P95Ms, next.P99Ms, next.ErrorRate = 0, 0, 0, 0, 0
+	}
+
+	next.ObservedAt = observedAt
+	next.EwmaMs = ewma(next.EwmaMs, float64(latency.Microseconds())/1000)
+
+	if s.window.add(latency, err != nil) {
+		summary := s.window.summary()
+		next.P50Ms, next.P95Ms, next.P99Ms, next.ErrorRate = summary.p50Ms, summary.p95Ms, summary.p99Ms, summary.errorRate
+	}
+
+	s.snapshot.Store(&next)
 }
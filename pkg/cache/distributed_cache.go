@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/storage"
+)
+
+// DistributedCache mantém a leitura no AtomicCache local, sem ida ao banco no
+// caminho quente, e usa a tabela processor_health para que todas as réplicas
+// enxerguem o mesmo estado: quem faz o health check publica em
+// SetHealthCheck e as demais atualizam a cópia local em Sync.
+type DistributedCache interface {
+	AtomicCache
+	Sync(ctx context.Context) error
+}
+
+type DistributedCacheImp struct {
+	AtomicCache
+	pg storage.PostgresClient
+}
+
+func NewDistributedCache(local AtomicCache, pg storage.PostgresClient) DistributedCache {
+	return &DistributedCacheImp{
+		AtomicCache: local,
+		pg:          pg,
+	}
+}
+
+// SetHealthCheck publica sempre: é chamado só por quem faz o health check,
+// uma vez por ciclo.
+func (c *DistributedCacheImp) SetHealthCheck(processor string, state HealthState, minResponseTime int, checkedAt time.Time) {
+	c.AtomicCache.SetHealthCheck(processor, state, minResponseTime, checkedAt)
+	health := c.AtomicCache.GetProcessorHealth(processor)
+
+	sql := `
+		INSERT INTO processor_health (processor, state, failing, min_response_time, checked_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, now())
+		ON CONFLICT (processor) DO UPDATE
+		SET state = EXCLUDED.state,
+			failing = EXCLUDED.failing,
+			min_response_time = EXCLUDED.min_response_time,
+			checked_at = EXCLUDED.checked_at,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	if _, err := c.pg.Exec(context.Background(), sql, processor, string(health.State), health.Failing, health.MinResponseTime, health.CheckedAt); err != nil {
+		log.Printf("Erro ao publicar saúde do processador %s: %v", processor, err)
+	}
+}
+
+// Sync copia a tabela para o cache local. Quando o estado publicado é
+// unknown, a última leitura conclusiva é aplicada antes para que Failing e
+// CheckedAt fiquem iguais aos de quem publicou; degraded volta como failing,
+// o que não muda Failing. Uma linha com leitura mais velha que a local é
+// ignorada: a tabela sobrevive a um reinício, e as linhas da execução
+// anterior não podem trocar a saúde inicial por uma leitura vencida.
+func (c *DistributedCacheImp) Sync(ctx context.Context) error {
+	sql := `SELECT processor, state, failing, min_response_time, checked_at FROM processor_health`
+
+	rows, err := c.pg.Query(ctx, sql)
+	if err != nil {
+		return fmt.Errorf("erro consultar processor_health: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var processor, state string
+		var failing bool
+		var minResponseTime int
+		var checkedAt *time.Time
+
+		if err := rows.Scan(&processor, &state, &failing, &minResponseTime, &checkedAt); err != nil {
+			return fmt.Errorf("erro ler processor_health: %w", err)
+		}
+
+		if checkedAt == nil {
+			checkedAt = &time.Time{}
+		}
+
+		if checkedAt.Before(c.AtomicCache.GetProcessorHealth(processor).CheckedAt) {
+			continue
+		}
+
+		if HealthState(state) == HealthUnknown {
+			conclusive := HealthHealthy
+			if failing {
+				conclusive = HealthFailing
+			}
+			c.AtomicCache.SetHealthCheck(processor, conclusive, minResponseTime, *checkedAt)
+		}
+
+		c.AtomicCache.SetHealthCheck(processor, HealthState(state), minResponseTime, *checkedAt)
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"slices"
+	"time"
+)
+
+const (
+	windowSize    = 128
+	windowRefresh = 16
+	// ewmaAlpha é o peso da observação mais recente na média móvel.
+	ewmaAlpha = 0.2
+)
+
+func ewma(current, sample float64) float64 {
+	if current == 0 {
+		return sample
+	}
+
+	return ewmaAlpha*sample + (1-ewmaAlpha)*current
+}
+
+type windowSummary struct {
+	p50Ms     float64
+	p95Ms     float64
+	p99Ms     float64
+	errorRate float64
+}
+
+// latencyWindow guarda as últimas windowSize chamadas de um processador num
+// buffer circular. Não é seguro para uso concorrente; processorState
+// serializa o acesso.
+type latencyWindow struct {
+	latencies [windowSize]time.Duration
+	failed    [windowSize]bool
+	next      int
+	size      int
+	pending   int
+}
+
+func newLatencyWindow() *latencyWindow {
+	return &latencyWindow{}
+}
+
+// add devolve true quando já é hora de recalcular o resumo.
+func (w *latencyWindow) add(latency time.Duration, failed bool) bool {
+	w.latencies[w.next] = latency
+	w.failed[w.next] = failed
+	w.next = (w.next + 1) % windowSize
+	w.size = min(w.size+1, windowSize)
+	w.pending++
+
+	if w.pending < windowRefresh && w.size > windowRefresh {
+		return false
+	}
+
+	w.pending = 0
+	return true
+}
+
+func (w *latencyWindow) summary() windowSummary {
+	if w.size == 0 {
+		return windowSummary{}
+	}
+
+	latencies := make([]time.Duration, w.size)
+	copy(latencies, w.latencies[:w.size])
+	slices.Sort(latencies)
+
+	failures := 0
+	for _, failed := range w.failed[:w.size] {
+		if failed {
+			failures++
+		}
+	}
+
+	return windowSummary{
+		p50Ms:     percentileMs(latencies, 50),
+		p95Ms:     percentileMs(latencies, 95),
+		p99Ms:     percentileMs(latencies, 99),
+		errorRate: float64(failures) / float64(len(latencies)),
+	}
+}
+
+// percentileMs usa o método nearest-rank sobre latencies já ordenado.
+func percentileMs(latencies []time.Duration, p int) float64 {
+	idx := (len(latencies)*p + 99) / 100
+	return float64(latencies[max(idx-1, 0)].Microseconds()) / 1000
+}
@@ -0,0 +1,213 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Patrignani/patrignani-rinha-backend-go/pkg/clock"
+)
+
+type State string
+
+const (
+	StateClosed   State = "closed"
+	StateOpen     State = "open"
+	StateHalfOpen State = "half-open"
+)
+
+var ErrOpen = errors.New("circuito aberto")
+
+type CircuitBreaker interface {
+	Available() bool
+	Allow() bool
+	Record(err error)
+	Stats() Stats
+}
+
+// Options configura o disjuntor. O circuito abre quando, entre as últimas
+// WindowSize chamadas, há pelo menos MinRequests e a fração de falhas chega a
+// FailureThreshold. Depois de OpenTimeout ele passa a meio-aberto e libera até
+// HalfOpenProbes chamadas de teste: todas com sucesso fecham o circuito e
+// qualquer falha o reabre.
+type Options struct {
+	WindowSize       int
+	MinRequests      int
+	FailureThreshold float64
+	OpenTimeout      time.Duration
+	HalfOpenProbes   int
+	Clock            clock.Clock
+}
+
+type Stats struct {
+	State          State     `json:"state"`
+	Requests       int       `json:"requests"`
+	Failures       int       `json:"failures"`
+	FailureRate    float64   `json:"failureRate"`
+	OpenedAt       time.Time `json:"openedAt,omitzero"`
+	ProbesInFlight int       `json:"probesInFlight"`
+}
+
+type CircuitBreakerImp struct {
+	opts      Options
+	state     State
+	outcomes  []bool
+	next      int
+	size      int
+	failures  int
+	openedAt  time.Time
+	probes    int
+	successes int
+	mu        sync.Mutex
+}
+
+func New(opts Options) CircuitBreaker {
+	if opts.WindowSize < 1 {
+		opts.WindowSize = 1
+	}
+
+	if opts.HalfOpenProbes < 1 {
+		opts.HalfOpenProbes = 1
+	}
+
+	if opts.Clock == nil {
+		opts.Clock = clock.Real
+	}
+
+	return &CircuitBreakerImp{
+		opts:     opts,
+		state:    StateClosed,
+		outcomes: make([]bool, opts.WindowSize),
+	}
+}
+
+// Available informa, sem reservar nada, se uma chamada agora seria aceita.
+// O roteamento usa isso para escolher a fila; Allow é chamado na hora de
+// executar.
+func (b *CircuitBreakerImp) Available() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance()
+
+	switch b.state {
+	case StateOpen:
+		return false
+	case StateHalfOpen:
+		return b.probes < b.opts.HalfOpenProbes
+	default:
+		return true
+	}
+}
+
+// Allow reserva a chamada; em meio-aberto consome uma das sondas. Cada Allow
+// que devolve true precisa de um Record correspondente.
+func (b *CircuitBreakerImp) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance()
+
+	switch b.state {
+	case StateOpen:
+		return false
+	case StateHalfOpen:
+		if b.probes >= b.opts.HalfOpenProbes {
+			return false
+		}
+		b.probes++
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *CircuitBreakerImp) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	failed := err != nil
+
+	switch b.state {
+	case StateHalfOpen:
+		b.probes = max(b.probes-1, 0)
+		if failed {
+			b.open()
+			return
+		}
+
+		b.successes++
+		if b.successes >= b.opts.HalfOpenProbes {
+			b.close()
+		}
+	case StateClosed:
+		b.push(failed)
+		if b.size >= b.opts.MinRequests && b.failureRate() >= b.opts.FailureThreshold {
+			b.open()
+		}
+	}
+}
+
+func (b *CircuitBreakerImp) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance()
+
+	return Stats{
+		State:          b.state,
+		Requests:       b.size,
+		Failures:       b.failures,
+		FailureRate:    b.failureRate(),
+		OpenedAt:       b.openedAt,
+		ProbesInFlight: b.probes,
+	}
+}
+
+// advance passa de aberto para meio-aberto quando OpenTimeout venceu.
+func (b *CircuitBreakerImp) advance() {
+	if b.state == StateOpen && b.opts.Clock.Since(b.openedAt) >= b.opts.OpenTimeout {
+		b.state = StateHalfOpen
+		b.probes = 0
+		b.successes = 0
+	}
+}
+
+func (b *CircuitBreakerImp) open() {
+	b.state = StateOpen
+	b.openedAt = b.opts.Clock.Now()
+	b.probes = 0
+	b.successes = 0
+}
+
+func (b *CircuitBreakerImp) close() {
+	b.state = StateClosed
+	b.openedAt = time.Time{}
+	b.probes = 0
+	b.successes = 0
+	b.next = 0
+	b.size = 0
+	b.failures = 0
+}
+
+func (b *CircuitBreakerImp) push(failed bool) {
+	if b.size == len(b.outcomes) && b.outcomes[b.next] {
+		b.failures--
+	}
+
+	b.outcomes[b.next] = failed
+	if failed {
+		b.failures++
+	}
+
+	b.next = (b.next + 1) % len(b.outcomes)
+	b.size = min(b.size+1, len(b.outcomes))
+}
+
+func (b *CircuitBreakerImp) failureRate() float64 {
+	if b.size == 0 {
+		return 0
+	}
+
+	return float64(b.failures) / float64(b.size)
+}
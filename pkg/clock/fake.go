@@ -0,0 +1,194 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake é um Clock que só anda quando Advance ou Set são chamados. Timers e
+// tickers disparam durante Advance e, como os do pacote time, descartam o
+// disparo se o canal ainda estiver cheio.
+type Fake struct {
+	now     time.Time
+	waiters []*fakeWaiter
+	mu      sync.Mutex
+}
+
+func NewFake(start time.Time) *Fake {
+	return &Fake{now: start}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+// Sleep bloqueia até outra goroutine avançar o relógio em pelo menos d.
+func (f *Fake) Sleep(d time.Duration) {
+	<-f.NewTimer(d).C()
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	return f.addWaiter(d, 0)
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: intervalo não positivo para NewTicker")
+	}
+
+	return fakeTicker{f.addWaiter(d, d)}
+}
+
+func (f *Fake) Advance(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+// Set move o relógio para t e dispara, em ordem, tudo que venceu até lá.
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if t.Before(f.now) {
+		return
+	}
+
+	for {
+		next := f.nextDue(t)
+		if next == nil {
+			break
+		}
+
+		f.now = next.at
+		next.fire()
+	}
+
+	f.now = t
+}
+
+// Waiters informa quantos timers e tickers estão ativos. Testes usam isso
+// para saber que uma goroutine já está esperando antes de avançar o relógio.
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.waiters)
+}
+
+func (f *Fake) addWaiter(d, period time.Duration) *fakeWaiter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w := &fakeWaiter{
+		fake:   f,
+		at:     f.now.Add(d),
+		period: period,
+		ch:     make(chan time.Time, 1),
+	}
+
+	if d <= 0 {
+		w.ch <- f.now
+		if period == 0 {
+			return w
+		}
+		w.at = f.now.Add(period)
+	}
+
+	f.waiters = append(f.waiters, w)
+
+	return w
+}
+
+func (f *Fake) nextDue(limit time.Time) *fakeWaiter {
+	var next *fakeWaiter
+
+	for _, w := range f.waiters {
+		if w.at.After(limit) {
+			continue
+		}
+		if next == nil || w.at.Before(next.at) {
+			next = w
+		}
+	}
+
+	return next
+}
+
+func (f *Fake) remove(w *fakeWaiter) bool {
+	for i, candidate := range f.waiters {
+		if candidate == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+type fakeWaiter struct {
+	fake   *Fake
+	at     time.Time
+	period time.Duration
+	ch     chan time.Time
+}
+
+// fire é chamado com o lock do Fake já adquirido.
+func (w *fakeWaiter) fire() {
+	select {
+	case w.ch <- w.at:
+	default:
+	}
+
+	if w.period > 0 {
+		w.at = w.at.Add(w.period)
+		return
+	}
+
+	w.fake.remove(w)
+}
+
+func (w *fakeWaiter) C() <-chan time.Time {
+	return w.ch
+}
+
+func (w *fakeWaiter) Stop() bool {
+	w.fake.mu.Lock()
+	defer w.fake.mu.Unlock()
+
+	return w.fake.remove(w)
+}
+
+func (w *fakeWaiter) Reset(d time.Duration) bool {
+	w.fake.mu.Lock()
+	defer w.fake.mu.Unlock()
+
+	active := w.fake.remove(w)
+
+	select {
+	case <-w.ch:
+	default:
+	}
+
+	w.at = w.fake.now.Add(d)
+	if d <= 0 {
+		w.ch <- w.fake.now
+		return active
+	}
+
+	w.fake.waiters = append(w.fake.waiters, w)
+
+	return active
+}
+
+type fakeTicker struct {
+	waiter *fakeWaiter
+}
+
+func (t fakeTicker) C() <-chan time.Time { return t.waiter.C() }
+func (t fakeTicker) Stop()               { t.waiter.Stop() }
@@ -1,16 +1,36 @@
 package config
 
 import (
-	"log"
+	"errors"
 
 	"github.com/Netflix/go-env"
 )
 
 var Env Environment
 
-func init() {
-	_, err := env.UnmarshalFromEnviron(&Env)
-	if err != nil {
-		log.Fatal(err)
+// Load lê o ambiente para Env, monta a lista de processadores e valida a
+// combinação. main chama Load antes de tudo; fora dele, como nos testes, Env
+// fica zerado em vez de derrubar o processo por falta de variáveis.
+func Load() error {
+	if _, err := env.UnmarshalFromEnviron(&Env); err != nil {
+		return err
 	}
+
+	if err := normalizeProcessors(&Env); err != nil {
+		return err
+	}
+
+	return validate(&Env)
+}
+
+// validate recusa combinações que deixam a instância sem fonte de saúde.
+func validate(env *Environment) error {
+	// Com o health check em singleton, só uma réplica consulta os
+	// processadores; as demais dependem da tabela processor_health e ficariam
+	// com a leitura vencida no modo local.
+	if env.EnableCheckHealthCheck && env.HealthCheckSingleton && env.HealthState.Mode != "distributed" {
+		return errors.New("HEALTH_CHECK_SINGLETON exige HEALTH_MODE=distributed")
+	}
+
+	return nil
 }
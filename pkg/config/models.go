@@ -4,16 +4,35 @@ import "time"
 
 type Environment struct {
 	Postgres               Postgres
-	StartPort              string `env:"START_PORT,default=8080"`
+	StartPort              string     `env:"START_PORT,default=8080"`
+	Processors             Processors `env:"PROCESSORS"`
 	ScreeningQueue         QueueScreening
 	HighPriorityQueue      QueueHighPriority
 	LowPriorityQueue       QueueLowPriority
 	WaitingRoomQueue       QueueLowWaiting
+	UrgentQueue            QueueUrgent
+	Deadline               Deadline
+	MessageLog             MessageLog
+	QueueBackend           QueueBackend
+	Shutdown               Shutdown
+	DeadLetter             DeadLetter
+	AdaptiveWorkers        AdaptiveWorkers
+	InsertBatch            InsertBatch
+	Retry                  Retry
+	RateLimit              RateLimit
+	HealthState            HealthState
+	CircuitBreaker         CircuitBreaker
+	Routing                Routing
+	Fees                   Fees
+	PoisonMaxPanics        int           `env:"POISON_MAX_PANICS,default=3"`
+	QueueFullStatus        int           `env:"QUEUE_FULL_STATUS,default=503"`
+	QueueFullRetryAfter    int           `env:"QUEUE_FULL_RETRY_AFTER,default=1"`
 	LimitTimeHealth        int           `env:"LIMIT_TIME_HEALTH"`
 	WaitingRoomSleepTime   time.Duration `env:"WAITING_ROOM_SLEEP_TIME"`
 	DefaultUrl             string        `env:"DEFAULT_URL"`
 	FallbackUrl            string        `env:"FALLBACK_URL"`
 	EnableCheckHealthCheck bool          `env:"ENABLE_CHECK_HEALTH_CHECK"`
+	HealthCheckSingleton   bool          `env:"HEALTH_CHECK_SINGLETON"`
 	CalcRedirect           int           `env:"CALC_REDIRECT_CHANCE"`
 }
 
@@ -25,22 +44,125 @@ type Postgres struct {
 	PORT string `env:"DB_PORT,default=5432"`
 }
 
+type MessageLog struct {
+	Path            string        `env:"WAL_PATH"`
+	Sync            bool          `env:"WAL_SYNC"`
+	CompactInterval time.Duration `env:"WAL_COMPACT_INTERVAL,default=10s"`
+}
+
+type QueueBackend struct {
+	Kind         string        `env:"QUEUE_BACKEND,default=memory"`
+	PollInterval time.Duration `env:"QUEUE_POLL_INTERVAL,default=20ms"`
+	ClaimTimeout time.Duration `env:"QUEUE_CLAIM_TIMEOUT,default=30s"`
+}
+
+type HealthState struct {
+	Mode         string        `env:"HEALTH_MODE,default=local"`
+	SyncInterval time.Duration `env:"HEALTH_SYNC_INTERVAL,default=500ms"`
+	StaleAfter   time.Duration `env:"HEALTH_STALE_AFTER,default=15s"`
+	Unknown      string        `env:"HEALTH_UNKNOWN_POLICY,default=failing"`
+	// ObservationTTL é por quanto tempo a latência observada no tráfego vale
+	// para o roteamento depois da última chamada ao processador.
+	ObservationTTL time.Duration `env:"HEALTH_OBSERVATION_TTL,default=3s"`
+}
+
+type CircuitBreaker struct {
+	WindowSize       int           `env:"CIRCUIT_BREAKER_WINDOW,default=20"`
+	MinRequests      int           `env:"CIRCUIT_BREAKER_MIN_REQUESTS,default=10"`
+	FailureThreshold float64       `env:"CIRCUIT_BREAKER_FAILURE_THRESHOLD,default=0.5"`
+	OpenTimeout      time.Duration `env:"CIRCUIT_BREAKER_OPEN_TIMEOUT,default=2s"`
+	HalfOpenProbes   int           `env:"CIRCUIT_BREAKER_HALF_OPEN_PROBES,default=3"`
+}
+
+type Routing struct {
+	Policy        string        `env:"ROUTING_POLICY,default=strict"`
+	LatencyMargin time.Duration `env:"ROUTING_LATENCY_MARGIN,default=5ms"`
+	LatencyCost   float64       `env:"ROUTING_LATENCY_COST,default=0.0001"`
+	LatencyBudget float64       `env:"ROUTING_LATENCY_BUDGET,default=2"`
+}
+
+// Fees, RateLimit e as filas de alta e baixa prioridade só valem quando
+// PROCESSORS está vazio; ver legacyProcessors.
+type Fees struct {
+	Default  float64 `env:"DEFAULT_FEE_RATE,default=0.05"`
+	Fallback float64 `env:"FALLBACK_FEE_RATE,default=0.15"`
+}
+
+type Shutdown struct {
+	Timeout      time.Duration `env:"SHUTDOWN_TIMEOUT,default=10s"`
+	SnapshotPath string        `env:"SNAPSHOT_PATH"`
+}
+
+type DeadLetter struct {
+	MaxAttempts int           `env:"DEAD_LETTER_MAX_ATTEMPTS"`
+	MaxAge      time.Duration `env:"DEAD_LETTER_MAX_AGE"`
+	Path        string        `env:"DEAD_LETTER_PATH"`
+}
+
+type AdaptiveWorkers struct {
+	Enabled        bool          `env:"ADAPTIVE_WORKERS"`
+	Min            int           `env:"ADAPTIVE_WORKERS_MIN,default=4"`
+	Max            int           `env:"ADAPTIVE_WORKERS_MAX,default=64"`
+	LatencyTarget  time.Duration `env:"ADAPTIVE_WORKERS_LATENCY_TARGET,default=200ms"`
+	ErrorThreshold float64       `env:"ADAPTIVE_WORKERS_ERROR_THRESHOLD,default=0.1"`
+	Interval       time.Duration `env:"ADAPTIVE_WORKERS_INTERVAL,default=1s"`
+}
+
+type InsertBatch struct {
+	Size     int           `env:"INSERT_BATCH_SIZE"`
+	Interval time.Duration `env:"INSERT_BATCH_INTERVAL,default=5ms"`
+	Retries  int           `env:"INSERT_BATCH_RETRIES,default=3"`
+}
+
+type Retry struct {
+	BaseDelay           time.Duration `env:"RETRY_BASE_DELAY"`
+	Multiplier          float64       `env:"RETRY_MULTIPLIER,default=1"`
+	MaxDelay            time.Duration `env:"RETRY_MAX_DELAY,default=5s"`
+	Jitter              string        `env:"RETRY_JITTER,default=none"`
+	BaseChance          int           `env:"RETRY_BASE_CHANCE,default=30"`
+	ChanceIncrement     int           `env:"RETRY_CHANCE_INCREMENT,default=10"`
+	MaxChance           int           `env:"RETRY_MAX_CHANCE,default=80"`
+	FallbackProbeChance int           `env:"RETRY_FALLBACK_PROBE_CHANCE,default=50"`
+}
+
+type Deadline struct {
+	MaxAge time.Duration `env:"MESSAGE_DEADLINE"`
+	Action string        `env:"MESSAGE_DEADLINE_ACTION,default=urgent"`
+}
+
+type RateLimit struct {
+	DefaultRate   float64 `env:"DEFAULT_RATE_LIMIT"`
+	DefaultBurst  int     `env:"DEFAULT_RATE_BURST,default=1"`
+	FallbackRate  float64 `env:"FALLBACK_RATE_LIMIT"`
+	FallbackBurst int     `env:"FALLBACK_RATE_BURST,default=1"`
+}
+
 type QueueScreening struct {
-	Buffer  int `env:"SCREENING_BUFFER"`
-	Workers int `env:"SCREENING_WORKERS"`
+	Buffer     int `env:"SCREENING_BUFFER"`
+	Workers    int `env:"SCREENING_WORKERS"`
+	MaxPending int `env:"SCREENING_MAX_PENDING"`
 }
 
 type QueueHighPriority struct {
-	Buffer  int `env:"HIGH_PRIORITY_BUFFER"`
-	Workers int `env:"HIGH_PRIORITY_WORKERS"`
+	Buffer     int `env:"HIGH_PRIORITY_BUFFER"`
+	Workers    int `env:"HIGH_PRIORITY_WORKERS"`
+	MaxPending int `env:"HIGH_PRIORITY_MAX_PENDING"`
 }
 
 type QueueLowPriority struct {
-	Buffer  int `env:"LOW_PRIORITY_BUFFER"`
-	Workers int `env:"LOW_PRIORITY_WORKERS"`
+	Buffer     int `env:"LOW_PRIORITY_BUFFER"`
+	Workers    int `env:"LOW_PRIORITY_WORKERS"`
+	MaxPending int `env:"LOW_PRIORITY_MAX_PENDING"`
 }
 
 type QueueLowWaiting struct {
-	Buffer  int `env:"WAITING_BUFFER"`
-	Workers int `env:"WAITING_WORKERS"`
+	Buffer     int `env:"WAITING_BUFFER"`
+	Workers    int `env:"WAITING_WORKERS"`
+	MaxPending int `env:"WAITING_MAX_PENDING"`
+}
+
+type QueueUrgent struct {
+	Buffer     int `env:"URGENT_BUFFER,default=1000"`
+	Workers    int `env:"URGENT_WORKERS,default=4"`
+	MaxPending int `env:"URGENT_MAX_PENDING"`
 }
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// Processor descreve um processador de pagamentos. Priority menor é
+// preferido pelo roteamento, Weight é a fatia dele na estratégia weighted e
+// Queue nomeia a fila que o atende (vazio usa Name). RateLimit zero desativa o
+// limite de chamadas.
+type Processor struct {
+	Name       string  `json:"name"`
+	Url        string  `json:"url"`
+	Fee        float64 `json:"fee"`
+	Priority   int     `json:"priority"`
+	Weight     int     `json:"weight"`
+	Queue      string  `json:"queue"`
+	Buffer     int     `json:"buffer"`
+	Workers    int     `json:"workers"`
+	MaxPending int     `json:"maxPending"`
+	RateLimit  float64 `json:"rateLimit"`
+	RateBurst  int     `json:"rateBurst"`
+}
+
+// Processors é lido de PROCESSORS como uma lista JSON.
+type Processors []Processor
+
+// legacySummaryKeys são as chaves do resumo de pagamentos de antes da lista
+// de processadores.
+var legacySummaryKeys = []string{"default", "fallback"}
+
+// LegacyAliases diz qual processador responde por default e fallback no
+// resumo quando nenhum tem esse nome: os de maior prioridade, em ordem, entre
+// os que não usam um desses nomes. Sem processador sobrando, o alias aponta
+// para "" e o resumo sai zerado.
+func (p Processors) LegacyAliases() map[string]string {
+	named := map[string]bool{}
+	for _, processor := range p {
+		named[processor.Name] = true
+	}
+
+	var candidates []string
+	for _, processor := range p {
+		if !slices.Contains(legacySummaryKeys, processor.Name) {
+			candidates = append(candidates, processor.Name)
+		}
+	}
+
+	aliases := map[string]string{}
+	for _, key := range legacySummaryKeys {
+		if named[key] {
+			continue
+		}
+
+		aliases[key] = ""
+		if len(candidates) > 0 {
+			aliases[key], candidates = candidates[0], candidates[1:]
+		}
+	}
+
+	return aliases
+}
+
+func (p *Processors) UnmarshalEnvironmentValue(data string) error {
+	var processors []Processor
+	if err := json.Unmarshal([]byte(data), &processors); err != nil {
+		return fmt.Errorf("PROCESSORS inválido: %w", err)
+	}
+
+	*p = processors
+	return nil
+}
+
+// normalizeProcessors monta a lista a partir das variáveis antigas quando
+// PROCESSORS não foi definido, preenche Queue, recusa nomes e filas
+// repetidos e ordena por Priority.
+func normalizeProcessors(env *Environment) error {
+	if len(env.Processors) == 0 {
+		env.Processors = legacyProcessors(env)
+	}
+
+	seen := map[string]bool{}
+	queues := map[string]bool{"screening": true, "waitingRoom": true, "urgent": true}
+	for i := range env.Processors {
+		processor := &env.Processors[i]
+
+		if processor.Name == "" || processor.Url == "" {
+			return fmt.Errorf("processador %d sem name ou url", i)
+		}
+
+		if seen[processor.Name] {
+			return fmt.Errorf("processador %s repetido", processor.Name)
+		}
+		seen[processor.Name] = true
+
+		if processor.Queue == "" {
+			processor.Queue = processor.Name
+		}
+
+		if queues[processor.Queue] {
+			return fmt.Errorf("fila %s do processador %s já está em uso", processor.Queue, processor.Name)
+		}
+		queues[processor.Queue] = true
+	}
+
+	sort.SliceStable(env.Processors, func(i, j int) bool {
+		return env.Processors[i].Priority < env.Processors[j].Priority
+	})
+
+	return nil
+}
+
+// legacyProcessors reproduz a configuração de dois processadores: o default
+// consumido pela fila lowPriority e o fallback pela highPriority, com
+// CALC_REDIRECT_CHANCE como a fatia do fallback.
+func legacyProcessors(env *Environment) Processors {
+	return Processors{
+		{
+			Name:       "default",
+			Url:        env.DefaultUrl,
+			Fee:        env.Fees.Default,
+			Priority:   0,
+			Weight:     100 - env.CalcRedirect,
+			Queue:      "lowPriority",
+			Buffer:     env.LowPriorityQueue.Buffer,
+			Workers:    env.LowPriorityQueue.Workers,
+			MaxPending: env.LowPriorityQueue.MaxPending,
+			RateLimit:  env.RateLimit.DefaultRate,
+			RateBurst:  env.RateLimit.DefaultBurst,
+		},
+		{
+			Name:       "fallback",
+			Url:        env.FallbackUrl,
+			Fee:        env.Fees.Fallback,
+			Priority:   1,
+			Weight:     env.CalcRedirect,
+			Queue:      "highPriority",
+			Buffer:     env.HighPriorityQueue.Buffer,
+			Workers:    env.HighPriorityQueue.Workers,
+			MaxPending: env.HighPriorityQueue.MaxPending,
+			RateLimit:  env.RateLimit.FallbackRate,
+			RateBurst:  env.RateLimit.FallbackBurst,
+		},
+	}
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestProcessorsLegacyAliases(t *testing.T) {
+	tests := []struct {
+		name       string
+		processors Processors
+		want       map[string]string
+	}{
+		{
+			name:       "configuração padrão não precisa de alias",
+			processors: Processors{{Name: "default"}, {Name: "fallback"}},
+			want:       map[string]string{},
+		},
+		{
+			name:       "nomes próprios seguem a prioridade",
+			processors: Processors{{Name: "stone"}, {Name: "cielo"}, {Name: "rede"}},
+			want:       map[string]string{"default": "stone", "fallback": "cielo"},
+		},
+		{
+			name:       "só falta o fallback",
+			processors: Processors{{Name: "stone"}, {Name: "default"}},
+			want:       map[string]string{"fallback": "stone"},
+		},
+		{
+			name:       "um processador só",
+			processors: Processors{{Name: "stone"}},
+			want:       map[string]string{"default": "stone", "fallback": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.processors.LegacyAliases(); !maps.Equal(got, tt.want) {
+				t.Errorf("LegacyAliases() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeProcessorsLegacy(t *testing.T) {
+	env := Environment{DefaultUrl: "http://default", FallbackUrl: "http://fallback", CalcRedirect: 20}
+
+	if err := normalizeProcessors(&env); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(env.Processors) != 2 {
+		t.Fatalf("len(Processors) = %d, want 2", len(env.Processors))
+	}
+
+	defaultApi, fallbackApi := env.Processors[0], env.Processors[1]
+	if defaultApi.Name != "default" || defaultApi.Queue != "lowPriority" || defaultApi.Weight != 80 {
+		t.Errorf("default = %+v", defaultApi)
+	}
+	if fallbackApi.Name != "fallback" || fallbackApi.Queue != "highPriority" || fallbackApi.Weight != 20 {
+		t.Errorf("fallback = %+v", fallbackApi)
+	}
+}
+
+func TestNormalizeProcessorsRejectsInvalid(t *testing.T) {
+	tests := []Processors{
+		{{Name: "a", Url: "http://a"}, {Name: "a", Url: "http://a"}},
+		{{Name: "a", Url: "http://a", Queue: "q"}, {Name: "b", Url: "http://b", Queue: "q"}},
+		{{Name: "a", Url: "http://a", Queue: "screening"}},
+		{{Url: "http://sem-nome"}},
+		{{Name: "sem-url"}},
+	}
+
+	for _, processors := range tests {
+		env := Environment{Processors: processors}
+		if err := normalizeProcessors(&env); err == nil {
+			t.Errorf("normalizeProcessors(%+v) não recusou", processors)
+		}
+	}
+}
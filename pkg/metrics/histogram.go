@@ -0,0 +1,182 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	minBound     = 100 * time.Microsecond
+	bucketGrowth = 1.2
+	bucketCount  = 72
+)
+
+// bounds guarda o limite superior de cada bucket, crescendo 20% a cada passo
+// a partir de 100µs. Com 72 buckets o último cobre pouco mais de 60s, e o
+// erro de qualquer percentil fica limitado à largura de um bucket.
+var bounds = func() []time.Duration {
+	b := make([]time.Duration, bucketCount)
+	for i := range b {
+		b[i] = time.Duration(float64(minBound) * math.Pow(bucketGrowth, float64(i)))
+	}
+	return b
+}()
+
+type HistogramSnapshot struct {
+	Count  int64   `json:"count"`
+	MeanMs float64 `json:"meanMs"`
+	P50Ms  float64 `json:"p50Ms"`
+	P90Ms  float64 `json:"p90Ms"`
+	P99Ms  float64 `json:"p99Ms"`
+	MaxMs  float64 `json:"maxMs"`
+}
+
+// Histogram registra durações em buckets exponenciais usando apenas
+// operações atômicas, então pode ser chamado do caminho quente sem lock.
+// Um *Histogram nil ignora as gravações.
+type Histogram struct {
+	buckets [bucketCount + 1]atomic.Int64
+	count   atomic.Int64
+	sum     atomic.Int64
+	max     atomic.Int64
+}
+
+func NewHistogram() *Histogram {
+	return &Histogram{}
+}
+
+func (h *Histogram) Record(d time.Duration) {
+	if h == nil {
+		return
+	}
+
+	if d < 0 {
+		d = 0
+	}
+
+	idx := sort.Search(bucketCount, func(i int) bool { return bounds[i] >= d })
+	h.buckets[idx].Add(1)
+	h.count.Add(1)
+	h.sum.Add(int64(d))
+
+	for {
+		current := h.max.Load()
+		if int64(d) <= current || h.max.CompareAndSwap(current, int64(d)) {
+			return
+		}
+	}
+}
+
+func (h *Histogram) Since(start time.Time) {
+	h.Record(time.Since(start))
+}
+
+// Percentile devolve o limite superior do bucket onde cai o percentil p
+// (0 a 100), limitado ao maior valor observado.
+func (h *Histogram) Percentile(p float64) time.Duration {
+	if h == nil {
+		return 0
+	}
+
+	count := h.count.Load()
+	if count == 0 {
+		return 0
+	}
+
+	rank := int64(math.Ceil(p / 100 * float64(count)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	maxObserved := time.Duration(h.max.Load())
+
+	var seen int64
+	for i := range h.buckets {
+		seen += h.buckets[i].Load()
+		if seen >= rank {
+			if i >= bucketCount || bounds[i] > maxObserved {
+				return maxObserved
+			}
+			return bounds[i]
+		}
+	}
+
+	return maxObserved
+}
+
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	if h == nil {
+		return HistogramSnapshot{}
+	}
+
+	count := h.count.Load()
+	snapshot := HistogramSnapshot{
+		Count: count,
+		P50Ms: toMs(h.Percentile(50)),
+		P90Ms: toMs(h.Percentile(90)),
+		P99Ms: toMs(h.Percentile(99)),
+		MaxMs: toMs(time.Duration(h.max.Load())),
+	}
+
+	if count > 0 {
+		snapshot.MeanMs = toMs(time.Duration(h.sum.Load() / count))
+	}
+
+	return snapshot
+}
+
+type Registry interface {
+	Histogram(name string) *Histogram
+	Snapshot() map[string]HistogramSnapshot
+}
+
+type RegistryImp struct {
+	histograms map[string]*Histogram
+	mu         sync.RWMutex
+}
+
+func NewRegistry() Registry {
+	return &RegistryImp{
+		histograms: map[string]*Histogram{},
+	}
+}
+
+func (r *RegistryImp) Histogram(name string) *Histogram {
+	r.mu.RLock()
+	h, ok := r.histograms[name]
+	r.mu.RUnlock()
+	if ok {
+		return h
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if h, ok := r.histograms[name]; ok {
+		return h
+	}
+
+	h = NewHistogram()
+	r.histograms[name] = h
+
+	return h
+}
+
+func (r *RegistryImp) Snapshot() map[string]HistogramSnapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	snapshot := make(map[string]HistogramSnapshot, len(r.histograms))
+	for name, h := range r.histograms {
+		snapshot[name] = h.Snapshot()
+	}
+
+	return snapshot
+}
+
+func toMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -25,16 +25,31 @@ type PaymentBasic struct {
 type PaymentDb struct {
 	CorrelationId string
 	Amount        decimal.Decimal
-	Fallback      bool
+	Processor     string
 	CreatedAt     time.Time
 }
 
 type PaymentSummary struct {
 	TotalRequests int     `json:"totalRequests"`
 	TotalAmount   float64 `json:"totalAmount"`
+	TotalFee      float64 `json:"totalFee"`
+	NetAmount     float64 `json:"netAmount"`
 }
 
-type SummaryResponse struct {
-	Default  PaymentSummary `json:"default"`
-	Fallback PaymentSummary `json:"fallback"`
+// WithFee preenche a taxa cobrada sobre TotalAmount e o valor líquido,
+// arredondados em centavos.
+func (s PaymentSummary) WithFee(rate float64) PaymentSummary {
+	amount := decimal.NewFromFloat(s.TotalAmount)
+	fee := amount.Mul(decimal.NewFromFloat(rate)).Round(2)
+
+	s.TotalFee = fee.InexactFloat64()
+	s.NetAmount = amount.Sub(fee).Round(2).InexactFloat64()
+
+	return s
 }
+
+// SummaryResponse é indexado pelo nome do processador. As chaves default e
+// fallback estão sempre presentes: com nomes próprios em PROCESSORS elas
+// repetem os processadores de maior prioridade (ver
+// config.Processors.LegacyAliases).
+type SummaryResponse map[string]PaymentSummary
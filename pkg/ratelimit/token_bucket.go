@@ -0,0 +1,153 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type RateLimiter interface {
+	Wait(ctx context.Context) (time.Duration, error)
+	SetRate(rate float64, burst int)
+	Stats() Stats
+}
+
+type Stats struct {
+	Rate        float64 `json:"rate"`
+	Burst       int     `json:"burst"`
+	Waits       int64   `json:"waits"`
+	Delayed     int64   `json:"delayed"`
+	TotalWaitMs int64   `json:"totalWaitMs"`
+	AvgWaitMs   float64 `json:"avgWaitMs"`
+	MaxWaitMs   int64   `json:"maxWaitMs"`
+}
+
+// TokenBucketImp libera rate tokens por segundo, acumulando até burst. Rate
+// zero desativa o limite. Wait reserva o próximo token e dorme até ele ficar
+// disponível, então chamadas concorrentes saem espaçadas em vez de todas
+// acordarem juntas.
+type TokenBucketImp struct {
+	rate      float64
+	burst     int
+	tokens    float64
+	last      time.Time
+	waits     int64
+	delayed   int64
+	totalWait time.Duration
+	maxWait   time.Duration
+	mu        sync.Mutex
+}
+
+func NewTokenBucket(rate float64, burst int) RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &TokenBucketImp{
+		rate:   rate,
+		burst:  burst,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+func (b *TokenBucketImp) Wait(ctx context.Context) (time.Duration, error) {
+	wait := b.reserve()
+	if wait <= 0 {
+		return 0, nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		b.refund()
+		return 0, ctx.Err()
+	case <-timer.C:
+		return wait, nil
+	}
+}
+
+func (b *TokenBucketImp) SetRate(rate float64, burst int) {
+	if burst < 1 {
+		burst = 1
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(time.Now())
+	b.rate = rate
+	b.burst = burst
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+}
+
+func (b *TokenBucketImp) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := Stats{
+		Rate:        b.rate,
+		Burst:       b.burst,
+		Waits:       b.waits,
+		Delayed:     b.delayed,
+		TotalWaitMs: b.totalWait.Milliseconds(),
+		MaxWaitMs:   b.maxWait.Milliseconds(),
+	}
+
+	if b.waits > 0 {
+		stats.AvgWaitMs = float64(b.totalWait.Microseconds()) / float64(b.waits) / 1000
+	}
+
+	return stats
+}
+
+func (b *TokenBucketImp) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.waits++
+
+	if b.rate <= 0 {
+		return 0
+	}
+
+	b.refill(time.Now())
+	b.tokens--
+
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	wait := time.Duration(-b.tokens / b.rate * float64(time.Second))
+
+	b.delayed++
+	b.totalWait += wait
+	if wait > b.maxWait {
+		b.maxWait = wait
+	}
+
+	return wait
+}
+
+func (b *TokenBucketImp) refund() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.rate > 0 {
+		b.tokens++
+	}
+}
+
+func (b *TokenBucketImp) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+
+	b.tokens += elapsed * b.rate
+	if b.tokens > float64(b.burst) {
+		b.tokens = float64(b.burst)
+	}
+}
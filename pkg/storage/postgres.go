@@ -13,6 +13,15 @@ type PostgresClient interface {
 	Exec(ctx context.Context, sql string, args ...interface{}) (int64, error)
 	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
 	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	TryAdvisoryLock(ctx context.Context, key int64) (AdvisoryLock, bool, error)
+}
+
+// AdvisoryLock mantém a conexão em que o lock foi obtido: advisory locks
+// pertencem à sessão, então a conexão não pode voltar ao pool enquanto o lock
+// estiver ativo, e o Postgres o solta sozinho se a conexão cair.
+type AdvisoryLock interface {
+	Ping(ctx context.Context) error
+	Release(ctx context.Context) error
 }
 
 type PostgresClientImp struct {
@@ -46,3 +55,47 @@ func (p *PostgresClientImp) QueryRow(ctx context.Context, sql string, args ...in
 func (p *PostgresClientImp) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
 	return p.pool.Query(ctx, sql, args...)
 }
+
+// TryAdvisoryLock tenta pg_try_advisory_lock numa conexão dedicada do pool.
+// Devolve false, sem erro, quando outra sessão já detém o lock.
+func (p *PostgresClientImp) TryAdvisoryLock(ctx context.Context, key int64) (AdvisoryLock, bool, error) {
+	conn, err := p.pool.Acquire(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("erro obter conexão: %w", err)
+	}
+
+	var acquired bool
+	if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+		conn.Release()
+		return nil, false, fmt.Errorf("erro obter advisory lock: %w", err)
+	}
+
+	if !acquired {
+		conn.Release()
+		return nil, false, nil
+	}
+
+	return &advisoryLockImp{conn: conn, key: key}, true, nil
+}
+
+type advisoryLockImp struct {
+	conn *pgxpool.Conn
+	key  int64
+}
+
+func (l *advisoryLockImp) Ping(ctx context.Context) error {
+	return l.conn.Ping(ctx)
+}
+
+// Release solta o lock e devolve a conexão. Se o unlock falhar a conexão é
+// fechada, o que encerra a sessão e libera o lock do mesmo jeito.
+func (l *advisoryLockImp) Release(ctx context.Context) error {
+	defer l.conn.Release()
+
+	if _, err := l.conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", l.key); err != nil {
+		l.conn.Conn().Close(ctx)
+		return fmt.Errorf("erro liberar advisory lock: %w", err)
+	}
+
+	return nil
+}